@@ -0,0 +1,145 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/game-repository/api/game"
+	"github.com/alarmfox/game-repository/api/robot"
+	"github.com/alarmfox/game-repository/model"
+	"github.com/alarmfox/game-repository/storage"
+)
+
+const (
+	manifestName    = "manifest.json"
+	manifestVersion = 2
+)
+
+// Manifest describes an exported game. Players are referenced by account id
+// and turn files by their entry name in the archive, so that an archive does
+// not depend on the ids of the instance it comes from.
+type Manifest struct {
+	Version int     `json:"version"`
+	Game    Game    `json:"game"`
+	Robots  []Robot `json:"robots"`
+}
+
+type Game struct {
+	Name           string      `json:"name"`
+	Description    string      `json:"description"`
+	Difficulty     string      `json:"difficulty"`
+	Status         game.Status `json:"status"`
+	CurrentRound   int         `json:"currentRound"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	StartedAt      *time.Time  `json:"startedAt"`
+	ClosedAt       *time.Time  `json:"closedAt"`
+	RoundTimeLimit *int64      `json:"roundTimeLimit,omitempty"`
+	Players        []Player    `json:"players"`
+	Rounds         []Round     `json:"rounds"`
+}
+
+type Player struct {
+	AccountID string `json:"accountId"`
+	IsWinner  bool   `json:"isWinner"`
+}
+
+type Round struct {
+	Order       int        `json:"order"`
+	TestClassId string     `json:"testClassId"`
+	StartedAt   *time.Time `json:"startedAt"`
+	ClosedAt    *time.Time `json:"closedAt"`
+	TimeLimit   *int64     `json:"timeLimit,omitempty"`
+	Turns       []Turn     `json:"turns"`
+}
+
+type Turn struct {
+	AccountID string        `json:"accountId"`
+	Scores    *model.Scores `json:"scores"`
+	IsWinner  bool          `json:"isWinner"`
+	StartedAt *time.Time    `json:"startedAt"`
+	ClosedAt  *time.Time    `json:"closedAt"`
+	Forfeited bool          `json:"forfeited,omitempty"`
+	File      string        `json:"file,omitempty"`
+}
+
+type Robot struct {
+	TestClassId string          `json:"testClassId"`
+	Difficulty  string          `json:"difficulty"`
+	Type        robot.RobotType `json:"type"`
+	Scores      *model.Scores   `json:"scores"`
+}
+
+// Archive is an exported game ready to be written as a zip
+type Archive struct {
+	Manifest Manifest
+	files    []file
+	storage  storage.Storage
+}
+
+// file is a turn file stored under path and written in the archive as name
+type file struct {
+	name string
+	path string
+}
+
+func (a *Archive) Write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	mw, err := zw.Create(manifestName)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a.Manifest); err != nil {
+		return err
+	}
+
+	for _, f := range a.files {
+		if err := a.copyToZip(zw, f.name, f.path); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func (a *Archive) copyToZip(zw *zip.Writer, name, path string) error {
+	f, err := a.storage.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// turn files are already compressed
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:   name,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fw, f)
+	return err
+}
+
+func fileName(turnId int64) string {
+	return fmt.Sprintf("turns/%s.zip", strconv.FormatInt(turnId, 10))
+}
+
+type KeyType int64
+
+func (c KeyType) Parse(s string) (KeyType, error) {
+	a, err := strconv.ParseInt(s, 10, 64)
+	return KeyType(a), err
+}
+
+func (k KeyType) AsInt64() int64 {
+	return int64(k)
+}
@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/alarmfox/game-repository/api"
+)
+
+type Service interface {
+	Export(id int64) (*Archive, error)
+	Import(r io.Reader) (int64, error)
+}
+
+type Controller struct {
+	service Service
+}
+
+func NewController(as Service) *Controller {
+	return &Controller{service: as}
+}
+
+func (ac *Controller) Export(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	a, err := ac.service.Export(id.AsInt64())
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=game-%d.zip", id.AsInt64()))
+
+	// headers are already sent: errors are logged and the transfer is
+	// aborted, so that clients do not take a truncated zip as complete
+	if err := a.Write(w); err != nil {
+		log.Printf("cannot export game %d: %v", id.AsInt64(), err)
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+func (ac *Controller) Import(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := ac.service.Import(r.Body)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+	defer r.Body.Close()
+
+	return api.WriteJson(w, http.StatusCreated, map[string]any{"id": id})
+}
@@ -0,0 +1,227 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/api/turn"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ControllerSuite struct {
+	suite.Suite
+	tServer *httptest.Server
+}
+
+func (suite *ControllerSuite) SetupSuite() {
+	ar := new(MockedRepository)
+	ar.
+		On("Export", int64(1)).
+		Return(&Archive{Manifest: Manifest{
+			Version: manifestVersion,
+			Game:    Game{Name: "test"},
+		}}, nil).
+		On("Export",
+			mock.MatchedBy(func(id int64) bool { return id != 1 })).
+		Return(nil, api.ErrNotFound).
+		On("Import", mock.Anything).
+		Return(int64(0), api.ErrNotAZip).
+		Once().
+		On("Import", mock.Anything).
+		Return(int64(2), nil)
+
+	controller := NewController(ar)
+
+	r := chi.NewMux()
+	r.Get("/{id}/export", api.HandlerFunc(controller.Export))
+	r.Post("/import", api.HandlerFunc(controller.Import))
+
+	suite.tServer = httptest.NewServer(r)
+}
+
+func (suite *ControllerSuite) TestExport() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Id             string
+	}{
+		{
+			Name:           "T07-01-GameExported",
+			ExpectedStatus: http.StatusOK,
+			Id:             "1",
+		},
+		{
+			Name:           "T07-02-GameNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Id:             "12",
+		},
+		{
+			Name:           "T07-03-BadId",
+			ExpectedStatus: http.StatusBadRequest,
+			Id:             "a",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s/%s/export", suite.tServer.URL, tc.Id))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+
+			if tc.ExpectedStatus != http.StatusOK {
+				return
+			}
+
+			content, err := io.ReadAll(res.Body)
+			suite.NoError(err)
+			zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+			suite.NoError(err)
+			suite.Len(zr.File, 1)
+
+			f, err := zr.Open(manifestName)
+			suite.NoError(err)
+			defer f.Close()
+
+			var m Manifest
+			suite.NoError(json.NewDecoder(f).Decode(&m))
+			suite.Equal("test", m.Game.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestImport() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+	}{
+		{
+			Name:           "T07-04-NotAZip",
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "T07-05-GameImported",
+			ExpectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Post(suite.tServer.URL+"/import", "application/zip", bytes.NewBufferString("content"))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestReadTurnFile() {
+	ar := NewRepository(nil, nil, turn.UploadPolicy{AllowedExtensions: []string{".java"}})
+
+	tcs := []struct {
+		Name    string
+		Content []byte
+		Err     error
+		Rule    string
+	}{
+		{
+			Name:    "T07-06-ValidTurnFile",
+			Content: zipOf(suite.T(), "src/test/FooTest.java"),
+		},
+		{
+			Name:    "T07-07-TurnFileNotAZip",
+			Content: []byte("not a zip"),
+			Err:     api.ErrNotAZip,
+		},
+		{
+			Name:    "T07-08-TurnFileTraversal",
+			Content: zipOf(suite.T(), "../../FooTest.java"),
+			Err:     api.ErrNotAZip,
+			Rule:    "safePaths",
+		},
+		{
+			Name:    "T07-09-TurnFileExtension",
+			Content: zipOf(suite.T(), "run.sh"),
+			Err:     api.ErrNotAZip,
+			Rule:    "allowedExtensions",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			// turn files are stored in the archive as they are
+			buf := new(bytes.Buffer)
+			zw := zip.NewWriter(buf)
+			w, err := zw.Create("turns/1.zip")
+			suite.NoError(err)
+			_, err = w.Write(tc.Content)
+			suite.NoError(err)
+			suite.NoError(zw.Close())
+			archive := buf.Bytes()
+
+			zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+			suite.NoError(err)
+
+			_, err = ar.readTurnFile(zr.File[0])
+			if tc.Err == nil {
+				suite.NoError(err, tc.Name)
+				return
+			}
+			suite.ErrorIs(err, tc.Err, tc.Name)
+			suite.ErrorContains(err, tc.Rule, tc.Name)
+		})
+	}
+}
+
+// zipOf builds a zip with empty files named names
+func zipOf(t *testing.T, names ...string) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestControllerSuite(t *testing.T) {
+	suite.Run(t, new(ControllerSuite))
+}
+
+func (suite *ControllerSuite) TearDownSuite() {
+	defer suite.tServer.Close()
+}
+
+type MockedRepository struct {
+	mock.Mock
+}
+
+func (ar *MockedRepository) Export(id int64) (*Archive, error) {
+	args := ar.Called(id)
+	v := args.Get(0)
+	if v == nil {
+		return nil, args.Error(1)
+	}
+	return v.(*Archive), args.Error(1)
+}
+
+func (ar *MockedRepository) Import(r io.Reader) (int64, error) {
+	args := ar.Called(r)
+	return args.Get(0).(int64), args.Error(1)
+}
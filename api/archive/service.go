@@ -0,0 +1,471 @@
+package archive
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/api/game"
+	"github.com/alarmfox/game-repository/api/robot"
+	"github.com/alarmfox/game-repository/api/turn"
+	"github.com/alarmfox/game-repository/model"
+	"github.com/alarmfox/game-repository/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db      *gorm.DB
+	storage storage.Storage
+	policy  turn.UploadPolicy
+}
+
+func NewRepository(db *gorm.DB, s storage.Storage, policy turn.UploadPolicy) *Repository {
+	return &Repository{
+		db:      db,
+		storage: s,
+		policy:  policy,
+	}
+}
+
+func (ar *Repository) Export(id int64) (*Archive, error) {
+	var (
+		g           model.Game
+		playerGames []model.PlayerGame
+		players     []model.Player
+		robots      []model.Robot
+	)
+
+	err := ar.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Preload("Rounds", func(db *gorm.DB) *gorm.DB {
+				return db.Order("\"order\" asc")
+			}).
+			Preload("Rounds.Turns", func(db *gorm.DB) *gorm.DB {
+				return db.Order("id asc")
+			}).
+			Preload("Rounds.Turns.Files", func(db *gorm.DB) *gorm.DB {
+				return db.Order("version asc")
+			}).
+			First(&g, id).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Where(&model.PlayerGame{GameID: id}).
+			Order("player_id asc").
+			Find(&playerGames).
+			Error
+		if err != nil {
+			return err
+		}
+
+		// turns may belong to players removed from the game
+		err = tx.
+			Where("id in (?)", tx.
+				Model(&model.Turn{}).
+				Select("player_id").
+				Joins("join rounds on rounds.id = turns.round_id").
+				Where("rounds.game_id = ?", id)).
+			Find(&players).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Where("test_class_id in (?)", tx.
+				Model(&model.Round{}).
+				Select("test_class_id").
+				Where(&model.Round{GameID: id})).
+			Order("id asc").
+			Find(&robots).
+			Error
+	})
+
+	if err != nil {
+		return nil, api.MakeServiceError(err)
+	}
+
+	accounts := make(map[int64]string, len(players))
+	for _, p := range players {
+		accounts[p.ID] = p.AccountID
+	}
+
+	a := Archive{
+		storage: ar.storage,
+		Manifest: Manifest{
+			Version: manifestVersion,
+			Game: Game{
+				Name:           g.Name,
+				Description:    g.Description.String,
+				Difficulty:     g.Difficulty,
+				Status:         game.Status(g.Status),
+				CurrentRound:   g.CurrentRound,
+				CreatedAt:      g.CreatedAt,
+				StartedAt:      g.StartedAt,
+				ClosedAt:       g.ClosedAt,
+				RoundTimeLimit: g.RoundTimeLimit,
+				Players:        make([]Player, len(playerGames)),
+				Rounds:         make([]Round, len(g.Rounds)),
+			},
+			Robots: make([]Robot, len(robots)),
+		},
+	}
+
+	for i, pg := range playerGames {
+		a.Manifest.Game.Players[i] = Player{
+			AccountID: pg.PlayerID,
+			IsWinner:  pg.IsWinner,
+		}
+	}
+
+	for i, r := range g.Rounds {
+		turns := make([]Turn, len(r.Turns))
+		for j, t := range r.Turns {
+			turns[j] = Turn{
+				AccountID: accounts[t.PlayerID],
+				Scores:    t.Scores,
+				IsWinner:  t.IsWinner,
+				StartedAt: t.StartedAt,
+				ClosedAt:  t.ClosedAt,
+				Forfeited: t.Forfeited,
+			}
+
+			// only the latest version is exported; files missing from the
+			// storage are left out
+			if len(t.Files) == 0 {
+				continue
+			}
+			latest := t.Files[len(t.Files)-1]
+			if ok, err := ar.storage.Exists(latest.Path); err != nil || !ok {
+				continue
+			}
+			turns[j].File = fileName(t.ID)
+			a.files = append(a.files, file{name: turns[j].File, path: latest.Path})
+		}
+
+		a.Manifest.Game.Rounds[i] = Round{
+			Order:       r.Order,
+			TestClassId: r.TestClassId,
+			StartedAt:   r.StartedAt,
+			ClosedAt:    r.ClosedAt,
+			TimeLimit:   r.TimeLimit,
+			Turns:       turns,
+		}
+	}
+
+	for i, r := range robots {
+		a.Manifest.Robots[i] = Robot{
+			TestClassId: r.TestClassId,
+			Difficulty:  r.Difficulty,
+			Type:        robot.RobotType(r.Type),
+			Scores:      r.Scores,
+		}
+	}
+
+	return &a, nil
+}
+
+func (ar *Repository) Import(r io.Reader) (int64, error) {
+	if r == nil {
+		return 0, fmt.Errorf("%w: body is empty", api.ErrInvalidParam)
+	}
+
+	src, err := os.CreateTemp("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	if _, err := io.Copy(src, r); err != nil {
+		return 0, api.MakeServiceError(err)
+	}
+
+	zfile, err := zip.OpenReader(src.Name())
+	if err != nil {
+		return 0, api.ErrNotAZip
+	}
+	defer zfile.Close()
+
+	manifest, entries, err := readManifest(&zfile.Reader)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		g = model.Game{
+			Name:           manifest.Game.Name,
+			Description:    sql.NullString{String: manifest.Game.Description, Valid: manifest.Game.Description != ""},
+			Difficulty:     manifest.Game.Difficulty,
+			Status:         manifest.Game.Status.AsModel(),
+			CurrentRound:   manifest.Game.CurrentRound,
+			CreatedAt:      manifest.Game.CreatedAt,
+			StartedAt:      manifest.Game.StartedAt,
+			ClosedAt:       manifest.Game.ClosedAt,
+			RoundTimeLimit: manifest.Game.RoundTimeLimit,
+		}
+		written []string
+	)
+
+	err = ar.db.Transaction(func(tx *gorm.DB) error {
+		accounts, err := createPlayers(tx, manifest)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(&g).Error; err != nil {
+			return err
+		}
+
+		if len(manifest.Game.Players) > 0 {
+			playerGames := make([]model.PlayerGame, len(manifest.Game.Players))
+			for i, p := range manifest.Game.Players {
+				playerGames[i] = model.PlayerGame{
+					PlayerID: p.AccountID,
+					GameID:   g.ID,
+					IsWinner: p.IsWinner,
+				}
+			}
+			if err := tx.Create(&playerGames).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, r := range manifest.Game.Rounds {
+			round := model.Round{
+				GameID:      g.ID,
+				Order:       r.Order,
+				TestClassId: r.TestClassId,
+				StartedAt:   r.StartedAt,
+				ClosedAt:    r.ClosedAt,
+				TimeLimit:   r.TimeLimit,
+			}
+			if err := tx.Create(&round).Error; err != nil {
+				return err
+			}
+
+			for _, t := range r.Turns {
+				turn := model.Turn{
+					RoundID:   round.ID,
+					PlayerID:  accounts[t.AccountID],
+					Scores:    t.Scores,
+					IsWinner:  t.IsWinner,
+					StartedAt: t.StartedAt,
+					ClosedAt:  t.ClosedAt,
+					Forfeited: t.Forfeited,
+				}
+				if err := tx.Create(&turn).Error; err != nil {
+					return err
+				}
+
+				if t.File == "" {
+					continue
+				}
+
+				metadata, err := ar.extract(tx, entries[t.File])
+				if metadata.Path != "" {
+					written = append(written, metadata.Path)
+				}
+				if err != nil {
+					return err
+				}
+
+				metadata.TurnID = sql.NullInt64{Int64: turn.ID, Valid: true}
+				metadata.UploadedBy = t.AccountID
+				if err := tx.Create(&metadata).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return createRobots(tx, manifest.Robots)
+	})
+
+	if err != nil {
+		for _, fname := range written {
+			ar.db.Transaction(func(tx *gorm.DB) error {
+				return turn.RemoveBlob(tx, ar.storage, fname)
+			})
+		}
+		return 0, api.MakeServiceError(err)
+	}
+
+	return g.ID, nil
+}
+
+// readManifest decodes and checks the manifest of an archive, returning it
+// together with the turn files referenced by the manifest
+func readManifest(zr *zip.Reader) (*Manifest, map[string]*zip.File, error) {
+	var manifest Manifest
+
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	mf, ok := entries[manifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: missing %s", api.ErrInvalidParam, manifestName)
+	}
+
+	rc, err := mf.Open()
+	if err != nil {
+		return nil, nil, api.ErrNotAZip
+	}
+	defer rc.Close()
+
+	if err := json.NewDecoder(io.LimitReader(rc, api.DefaultBodySize)).Decode(&manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid %s", api.ErrInvalidParam, manifestName)
+	}
+
+	if manifest.Version != manifestVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported archive version %d", api.ErrInvalidParam, manifest.Version)
+	}
+
+	for _, r := range manifest.Game.Rounds {
+		for _, t := range r.Turns {
+			if t.AccountID == "" {
+				return nil, nil, fmt.Errorf("%w: turn without player", api.ErrInvalidParam)
+			}
+			if _, ok := entries[t.File]; t.File != "" && !ok {
+				return nil, nil, fmt.Errorf("%w: missing %s", api.ErrInvalidParam, t.File)
+			}
+			if err := api.Validate(api.Optional("scores", t.Scores, api.Scores)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return &manifest, entries, nil
+}
+
+// createPlayers registers the players referenced by the manifest that are not
+// in the system yet and returns the ids of all of them by account id
+func createPlayers(tx *gorm.DB, m *Manifest) (map[string]int64, error) {
+	var (
+		accountIds []string
+		seen       = make(map[string]struct{})
+		players    []model.Player
+	)
+
+	add := func(accountId string) {
+		if _, ok := seen[accountId]; !ok {
+			seen[accountId] = struct{}{}
+			accountIds = append(accountIds, accountId)
+		}
+	}
+
+	for _, p := range m.Game.Players {
+		add(p.AccountID)
+	}
+	for _, r := range m.Game.Rounds {
+		for _, t := range r.Turns {
+			add(t.AccountID)
+		}
+	}
+
+	if len(accountIds) == 0 {
+		return nil, nil
+	}
+
+	players = make([]model.Player, len(accountIds))
+	for i, accountId := range accountIds {
+		players[i] = model.Player{AccountID: accountId}
+	}
+
+	err := tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&players).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	players = nil
+	err = tx.
+		Where("account_id in ?", accountIds).
+		Find(&players).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]int64, len(players))
+	for _, p := range players {
+		ids[p.AccountID] = p.ID
+	}
+
+	return ids, nil
+}
+
+// createRobots adds the robots that are not in the system yet
+func createRobots(tx *gorm.DB, robots []Robot) error {
+	for _, r := range robots {
+		rb := model.Robot{
+			TestClassId: r.TestClassId,
+			Difficulty:  r.Difficulty,
+			Type:        r.Type.AsInt8(),
+		}
+
+		err := tx.
+			Where(&rb).
+			Where("type = ?", rb.Type).
+			Attrs(model.Robot{Scores: r.Scores}).
+			FirstOrCreate(&rb).
+			Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extract copies a turn file from the archive to the storage using the same
+// layout as uploaded files
+func (ar *Repository) extract(tx *gorm.DB, f *zip.File) (model.Metadata, error) {
+	blob, err := ar.readTurnFile(f)
+	if err != nil {
+		return model.Metadata{}, err
+	}
+
+	return blob.Store(tx, ar.storage)
+}
+
+// readTurnFile reads a turn file from the archive, checking it as an upload:
+// it must be a zip satisfying the upload policy
+func (ar *Repository) readTurnFile(f *zip.File) (*turn.Blob, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, api.ErrNotAZip
+	}
+	defer rc.Close()
+
+	blob, err := turn.NewBlob(io.LimitReader(rc, api.MaxUploadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", api.ErrNotAZip, f.Name, err)
+	}
+
+	if blob.Size > api.MaxUploadSize {
+		return nil, fmt.Errorf("%w: %s is too large", api.ErrInvalidParam, f.Name)
+	}
+
+	zr, err := blob.OpenZip()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a zip", api.ErrNotAZip, f.Name)
+	}
+
+	if err := ar.policy.Validate(zr); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+
+	return blob, nil
+}
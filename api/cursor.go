@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Cursor points to a row by its (created_at, id) key. Clients see it as an
+// opaque string. Before is set on cursors pointing to the previous page.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+	Before    bool      `json:"b,omitempty"`
+}
+
+func (Cursor) Parse(s string) (Cursor, error) {
+	var c Cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("malformed cursor")
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("malformed cursor")
+	}
+
+	return c, nil
+}
+
+func (c Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// WithCursor selects the page following (or preceding, if the cursor is a
+// Before cursor) the cursor in p, ordering rows of table by (created_at, id).
+// One row more than the page size is fetched to detect further pages; rows
+// must be passed to MakeCursorResponse to be trimmed and put in order.
+func WithCursor(p PaginationParams, table string, desc bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		c := p.Cursor
+
+		// scanning backwards flips the order; rows are reversed afterwards
+		scanDesc := desc != c.Before
+		op, direction := ">", "asc"
+		if scanDesc {
+			op, direction = "<", "desc"
+		}
+
+		return db.
+			Where(fmt.Sprintf("(%[1]s.created_at, %[1]s.id) %[2]s (?, ?)", table, op), c.CreatedAt, c.ID).
+			Order(fmt.Sprintf("%s.created_at %s, %s.id %s", table, direction, table, direction)).
+			Limit(int(p.PageSize + 1))
+	}
+}
+
+// MakeCursorResponse builds a paginated response carrying the cursors of the
+// adjacent pages. If p has no cursor, rows are an offset page and only the
+// next cursor is computed, so that clients can switch to cursor pagination.
+func MakeCursorResponse[T any](rows []T, count int64, p PaginationParams, key func(T) Cursor) *PaginatedResponse {
+	if p.Cursor == nil {
+		res := MakePaginatedResponse(rows, count, p)
+		if res.Metadata.HasNext && len(rows) > 0 {
+			res.Metadata.Next = key(rows[len(rows)-1]).String()
+		}
+		return res
+	}
+
+	more := int64(len(rows)) > p.PageSize
+	if more {
+		rows = rows[:p.PageSize]
+	}
+
+	if p.Cursor.Before {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	metadata := PaginationMetadata{PageSize: p.PageSize}
+	if len(rows) > 0 {
+		next := key(rows[len(rows)-1])
+		prev := key(rows[0])
+		prev.Before = true
+
+		// the page the cursor comes from is always there
+		if !p.Cursor.Before || more {
+			metadata.Prev = prev.String()
+		}
+		if p.Cursor.Before || more {
+			metadata.Next = next.String()
+		}
+	}
+	metadata.HasNext = metadata.Next != ""
+
+	return &PaginatedResponse{
+		Data:     rows,
+		Metadata: metadata,
+	}
+}
+
+// SetLinkHeader writes the RFC 8288 Link header pointing to the adjacent
+// pages described in m
+func SetLinkHeader(w http.ResponseWriter, r *http.Request, m PaginationMetadata) {
+	var links []string
+
+	for _, l := range []struct{ cursor, rel string }{
+		{m.Next, "next"},
+		{m.Prev, "prev"},
+	} {
+		if l.cursor == "" {
+			continue
+		}
+		u := *r.URL
+		q := u.Query()
+		q.Del("page")
+		q.Set("cursor", l.cursor)
+		u.RawQuery = q.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), l.rel))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
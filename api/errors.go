@@ -9,10 +9,16 @@ import (
 )
 
 var (
-	ErrNotFound      = errors.New("not found")
-	ErrNotAZip       = errors.New("file is not a valid zip")
-	ErrDuplicatedKey = errors.New("already exists")
-	ErrInvalidParam  = errors.New("invalid param")
+	ErrNotFound           = errors.New("not found")
+	ErrNotAZip            = errors.New("file is not a valid zip")
+	ErrDuplicatedKey      = errors.New("already exists")
+	ErrInvalidParam       = errors.New("invalid param")
+	ErrInvalidState       = errors.New("invalid state")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrMethodNotAllowed   = errors.New("method not allowed")
+	ErrUnsupportedMedia   = errors.New("unsupported media type")
 )
 
 func MakeServiceError(err error) error {
@@ -27,32 +33,67 @@ func MakeServiceError(err error) error {
 }
 
 func MakeHttpError(err error) error {
-	var code int
+	return makeApiError(err)
+}
+
+func makeApiError(err error) ApiError {
+	var status int
+	var code Code
 	var message string
 
 	switch {
 	case errors.Is(err, ErrNotFound):
-		code = http.StatusNotFound
+		status = http.StatusNotFound
+		code = CodeNotFound
 		message = err.Error()
 	case errors.Is(err, ErrInvalidParam):
-		code = http.StatusBadRequest
+		status = http.StatusBadRequest
+		code = CodeInvalidParam
 		message = err.Error()
 	case errors.Is(err, ErrNotAZip):
-		code = http.StatusUnprocessableEntity
+		status = http.StatusUnprocessableEntity
+		code = CodeNotAZip
 		message = err.Error()
 	case errors.Is(err, ErrDuplicatedKey):
-		code = http.StatusConflict
+		status = http.StatusConflict
+		code = CodeDuplicatedKey
+		message = err.Error()
+	case errors.Is(err, ErrInvalidState):
+		status = http.StatusConflict
+		code = CodeInvalidState
+		message = err.Error()
+	case errors.Is(err, ErrPreconditionFailed):
+		status = http.StatusPreconditionFailed
+		code = CodePreconditionFailed
+		message = err.Error()
+	case errors.Is(err, ErrUnauthorized):
+		status = http.StatusUnauthorized
+		code = CodeUnauthorized
+		message = err.Error()
+	case errors.Is(err, ErrTooManyRequests):
+		status = http.StatusTooManyRequests
+		code = CodeTooManyRequests
+		message = err.Error()
+	case errors.Is(err, ErrMethodNotAllowed):
+		status = http.StatusMethodNotAllowed
+		code = CodeMethodNotAllowed
+		message = err.Error()
+	case errors.Is(err, ErrUnsupportedMedia):
+		status = http.StatusUnsupportedMediaType
+		code = CodeUnsupportedMedia
 		message = err.Error()
 	default:
 		if err, ok := err.(*http.MaxBytesError); ok {
-			code = http.StatusRequestEntityTooLarge
+			status = http.StatusRequestEntityTooLarge
+			code = CodeBodyTooLarge
 			message = fmt.Sprintf("allowed body size: %s", byteCountIEC(err.Limit))
 
 		} else {
-			code = http.StatusInternalServerError
+			status = http.StatusInternalServerError
+			code = CodeInternal
 			message = "internal server error"
 		}
 	}
 
-	return ApiError{code: code, Message: message, err: err}
+	return ApiError{status: status, code: code, Message: message, err: err}
 }
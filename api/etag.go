@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns the entity tag of a resource last updated at t. Timestamps are
+// stored with microsecond precision so finer digits are ignored.
+func ETag(t time.Time) string {
+	return fmt.Sprintf("\"%x\"", t.UnixMicro())
+}
+
+// Precondition is the If-Match header of an update request. The empty
+// precondition is satisfied by any entity.
+type Precondition string
+
+func IfMatch(r *http.Request) Precondition {
+	return Precondition(r.Header.Get("If-Match"))
+}
+
+// Matches reports whether the entity tagged etag satisfies the precondition
+func (p Precondition) Matches(etag string) bool {
+	return p == "" || matchETag(string(p), etag, false)
+}
+
+// WriteEntity writes v as json along with its entity tag. GET requests whose
+// If-None-Match lists etag are answered with 304 and no body.
+func WriteEntity(w http.ResponseWriter, r *http.Request, statusCode int, etag string, v any) error {
+	w.Header().Set("ETag", etag)
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" && r.Method == http.MethodGet && matchETag(inm, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	return WriteJson(w, statusCode, v)
+}
+
+// matchETag reports whether etag is in the comma separated list of tags. Weak
+// tags match only when weak is set (RFC 9110, section 8.8.3.2).
+func matchETag(list, etag string, weak bool) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,7 @@
 package game
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
@@ -10,9 +11,17 @@ import (
 type Service interface {
 	Create(request *CreateRequest) (Game, error)
 	FindById(id int64) (Game, error)
+	FindTreeById(id int64) (Tree, error)
 	Delete(id int64) error
-	Update(id int64, ug *UpdateRequest) (Game, error)
-	FindByInterval(accountId string, i api.IntervalParams, p api.PaginationParams) ([]Game, int64, error)
+	Update(id int64, ug *UpdateRequest, p api.Precondition) (Game, error)
+	Patch(id int64, ug *PatchRequest, p api.Precondition) (Game, error)
+	FindByFilter(f *Filter, p api.PaginationParams) ([]Game, int64, error)
+	Transition(id int64, to Status) (Game, error)
+	Close(id int64, deriveWinners bool) (Game, error)
+	SetWinners(id int64, r *WinnersRequest) (Game, error)
+	AddPlayers(id int64, r *PlayersRequest) (Game, error)
+	RemovePlayer(id int64, accountId string) error
+	Restore(id int64) (Game, error)
 }
 type Controller struct {
 	service Service
@@ -47,14 +56,41 @@ func (gc *Controller) FindByID(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	include, err := api.FromUrlQuery[IncludeType](r, "include", "")
+	if err != nil {
+		return err
+	}
+
+	if include == includeTree {
+		return gc.tree(w, r, id.AsInt64())
+	}
+
 	g, err := gc.service.FindById(id.AsInt64())
 
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, g)
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(g.UpdatedAt), g)
+}
+
+func (gc *Controller) FindTreeByID(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	return gc.tree(w, r, id.AsInt64())
+}
+
+func (gc *Controller) tree(w http.ResponseWriter, r *http.Request, id int64) error {
+	t, err := gc.service.FindTreeById(id)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
 
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(t.LastModified()), t)
 }
 
 func (gc *Controller) Delete(w http.ResponseWriter, r *http.Request) error {
@@ -83,47 +119,117 @@ func (gc *Controller) Update(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	g, err := gc.service.Update(id.AsInt64(), &request)
+	g, err := gc.service.Update(id.AsInt64(), &request, api.IfMatch(r))
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, g)
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(g.UpdatedAt), g)
+}
+
+func (gc *Controller) Patch(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[PatchRequest](r.Body)
+	if err != nil {
+		return err
+	}
+
+	g, err := gc.service.Patch(id.AsInt64(), &request, api.IfMatch(r))
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(g.UpdatedAt), g)
 }
 
 func (gc *Controller) List(w http.ResponseWriter, r *http.Request) error {
 	accountId, err := api.FromUrlQuery[AccountIdType](r, "accountId", "")
-
 	if err != nil {
 		return err
 	}
-	page, err := api.FromUrlQuery[KeyType](r, "page", 1)
 
+	page, err := api.FromUrlQuery[KeyType](r, "page", 1)
 	if err != nil {
 		return err
 	}
 
 	pageSize, err := api.FromUrlQuery[KeyType](r, "pageSize", 10)
-
 	if err != nil {
 		return err
 	}
 
 	startDate, err := api.FromUrlQuery(r, "startDate", IntervalType(time.Now().Add(-24*time.Hour)))
-
 	if err != nil {
 		return err
 	}
 
 	endDate, err := api.FromUrlQuery(r, "endDate", IntervalType(time.Now()))
+	if err != nil {
+		return err
+	}
 
+	difficulty, err := api.FromUrlQuery[CustomString](r, "difficulty", "")
 	if err != nil {
 		return err
 	}
 
-	ip := api.IntervalParams{
-		Start: startDate.AsTime(),
-		End:   endDate.AsTime(),
+	name, err := api.FromUrlQuery[CustomString](r, "name", "")
+	if err != nil {
+		return err
+	}
+
+	status, err := api.FromUrlQuery[StatusFilter](r, "status", "")
+	if err != nil {
+		return err
+	}
+
+	startedAt, err := fromUrlRange(r, "startedAtFrom", "startedAtTo")
+	if err != nil {
+		return err
+	}
+
+	closedAt, err := fromUrlRange(r, "closedAtFrom", "closedAtTo")
+	if err != nil {
+		return err
+	}
+
+	deleted, err := api.FromUrlQuery[BoolType](r, "deleted", false)
+	if err != nil {
+		return err
+	}
+
+	sort, err := api.FromUrlQuery(r, "sort", api.SortParams{Field: "createdAt", Desc: true})
+	if err != nil {
+		return err
+	}
+
+	if err := api.SortColumn(sort, sortColumns); err != nil {
+		return err
+	}
+
+	cursor, err := api.FromUrlQuery(r, "cursor", api.Cursor{})
+	if err != nil {
+		return err
+	}
+
+	f := Filter{
+		AccountID:  accountId.AsString(),
+		Difficulty: difficulty.AsString(),
+		Name:       name.AsString(),
+		Status:     status,
+		CreatedAt: api.IntervalParams{
+			Start: startDate.AsTime(),
+			End:   endDate.AsTime(),
+		},
+		StartedAt: startedAt,
+		ClosedAt:  closedAt,
+		Sort:      sort,
+		Deleted:   deleted.AsBool(),
 	}
 
 	pp := api.PaginationParams{
@@ -131,10 +237,161 @@ func (gc *Controller) List(w http.ResponseWriter, r *http.Request) error {
 		PageSize: pageSize.AsInt64(),
 	}
 
-	games, count, err := gc.service.FindByInterval(accountId.AsString(), ip, pp)
+	if cursor != (api.Cursor{}) {
+		if sort.Field != "createdAt" {
+			return api.MakeHttpError(fmt.Errorf("%w %q: cursor requires sorting by createdAt", api.ErrInvalidParam, "sort"))
+		}
+		pp.Cursor = &cursor
+	}
+
+	games, count, err := gc.service.FindByFilter(&f, pp)
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, api.MakePaginatedResponse(games, count, pp))
+	// cursors follow (created_at, id), so pages in any other order can only
+	// be reached by number
+	res := api.MakePaginatedResponse(games, count, pp)
+	if sort.Field == "createdAt" {
+		res = api.MakeCursorResponse(games, count, pp, cursorOf)
+	}
+	api.SetLinkHeader(w, r, res.Metadata)
+	return api.WriteJson(w, http.StatusOK, res)
+}
+
+// fromUrlRange reads an optional date range from the query parameters from
+// and to. Missing bounds are left to the zero time.
+func fromUrlRange(r *http.Request, from, to string) (api.IntervalParams, error) {
+	start, err := api.FromUrlQuery(r, from, IntervalType(time.Time{}))
+	if err != nil {
+		return api.IntervalParams{}, err
+	}
+
+	end, err := api.FromUrlQuery(r, to, IntervalType(time.Time{}))
+	if err != nil {
+		return api.IntervalParams{}, err
+	}
+
+	return api.IntervalParams{
+		Start: start.AsTime(),
+		End:   end.AsTime(),
+	}, nil
+}
+
+func (gc *Controller) Start(w http.ResponseWriter, r *http.Request) error {
+	return gc.transition(w, r, started)
+}
+
+func (gc *Controller) Pause(w http.ResponseWriter, r *http.Request) error {
+	return gc.transition(w, r, paused)
+}
+
+func (gc *Controller) Close(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	deriveWinners, err := api.FromUrlQuery[BoolType](r, "deriveWinners", false)
+	if err != nil {
+		return err
+	}
+
+	g, err := gc.service.Close(id.AsInt64(), deriveWinners.AsBool())
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, g)
+}
+
+func (gc *Controller) Restore(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	g, err := gc.service.Restore(id.AsInt64())
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, g)
+}
+
+func (gc *Controller) SetWinners(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[WinnersRequest](r.Body)
+	if err != nil {
+		return err
+	}
+
+	g, err := gc.service.SetWinners(id.AsInt64(), &request)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, g)
+}
+
+func (gc *Controller) transition(w http.ResponseWriter, r *http.Request, to Status) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	g, err := gc.service.Transition(id.AsInt64(), to)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, g)
+}
+
+func (gc *Controller) AddPlayers(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[PlayersRequest](r.Body)
+	if err != nil {
+		return err
+	}
+
+	g, err := gc.service.AddPlayers(id.AsInt64(), &request)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, g)
+}
+
+func (gc *Controller) RemovePlayer(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	accountId, err := api.FromUrlParams[AccountIdType](r, "accountId")
+	if err != nil {
+		return err
+	}
+
+	if err := gc.service.RemovePlayer(id.AsInt64(), accountId.AsString()); err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
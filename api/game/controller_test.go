@@ -2,11 +2,13 @@ package game
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/alarmfox/game-repository/api"
 	"github.com/go-chi/chi/v5"
@@ -22,37 +24,110 @@ type ControllerSuite struct {
 func (suite *ControllerSuite) SetupSuite() {
 	gr := new(MockedRepository)
 	gr.
-		On("Create", &CreateRequest{Name: ""}).
+		On("Create", &CreateRequest{Name: "test"}).
 		Return(Game{ID: 1}, nil).
 		On("FindById", int64(1)).
 		Return(Game{ID: 1}, nil).
 		On("FindById",
 			mock.MatchedBy(func(id int64) bool { return id != 1 })).
 		Return(nil, api.ErrNotFound).
+		On("FindTreeById", int64(1)).
+		Return(Tree{Game: Game{ID: 1}}, nil).
+		On("FindTreeById",
+			mock.MatchedBy(func(id int64) bool { return id != 1 })).
+		Return(nil, api.ErrNotFound).
 		On("Delete", int64(1)).
 		Return(nil).
 		On("Delete",
 			mock.MatchedBy(func(id int64) bool { return id != 1 })).
 		Return(api.ErrNotFound).
 		On("Update", int64(1),
-			&UpdateRequest{Name: "test", CurrentRound: 10}).
+			&UpdateRequest{Name: "test", CurrentRound: 10}, api.Precondition("")).
 		Return(Game{}, nil).
+		On("Update", int64(1),
+			&UpdateRequest{Name: "test", CurrentRound: 10}, api.Precondition(`"stale"`)).
+		Return(nil, api.ErrPreconditionFailed).
 		On("Update",
 			mock.MatchedBy(func(id int64) bool { return id != 1 }),
-			&UpdateRequest{Name: "test", CurrentRound: 10}).
+			&UpdateRequest{Name: "test", CurrentRound: 10}, mock.Anything).
 		Return(nil, api.ErrNotFound).
-		On("FindByInterval", mock.Anything, mock.Anything).
+		On("FindByFilter", mock.Anything,
+			mock.MatchedBy(func(p api.PaginationParams) bool { return p.Cursor != nil })).
+		Return(make([]Game, 11), int(0), nil).
+		On("FindByFilter",
+			mock.MatchedBy(func(f *Filter) bool { return f.Sort.Field == "updatedAt" }),
+			mock.Anything).
+		Return(make([]Game, 10), int(64), nil).
+		On("FindByFilter", mock.Anything, mock.Anything).
 		Return([]Game{}, int(64), nil).
 		On("FindByPlayer", mock.Anything, mock.Anything).
-		Return([]Game{}, int(64), nil)
+		Return([]Game{}, int(64), nil).
+		On("Transition", int64(1), started).
+		Return(Game{ID: 1, Status: started}, nil).
+		On("Transition", int64(1), mock.MatchedBy(func(s Status) bool { return s != started })).
+		Return(nil, api.ErrInvalidState).
+		On("Transition",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }),
+			mock.Anything).
+		Return(nil, api.ErrNotFound).
+		On("Close", int64(1), false).
+		Return(nil, api.ErrInvalidState).
+		On("Close", int64(1), true).
+		Return(Game{ID: 1, Status: closed}, nil).
+		On("SetWinners", int64(1), &WinnersRequest{Winners: []string{"a"}}).
+		Return(Game{ID: 1}, nil).
+		On("SetWinners", int64(1), &WinnersRequest{Winners: []string{"b"}}).
+		Return(nil, api.ErrInvalidParam).
+		On("SetWinners",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }),
+			mock.Anything).
+		Return(nil, api.ErrNotFound).
+		On("AddPlayers", int64(1), &PlayersRequest{Players: []string{"a"}}).
+		Return(Game{ID: 1}, nil).
+		On("AddPlayers", int64(1), &PlayersRequest{Players: []string{"b"}}).
+		Return(nil, api.ErrDuplicatedKey).
+		On("AddPlayers",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }),
+			mock.Anything).
+		Return(nil, api.ErrNotFound).
+		On("RemovePlayer", int64(1), "a").
+		Return(nil).
+		On("RemovePlayer", int64(1), mock.MatchedBy(func(a string) bool { return a != "a" })).
+		Return(api.ErrNotFound).
+		On("RemovePlayer", int64(2), mock.Anything).
+		Return(api.ErrInvalidState).
+		On("Patch", int64(1),
+			mock.MatchedBy(func(r *PatchRequest) bool { return r.Description.Null && r.Name.Value == "test" }),
+			api.Precondition("")).
+		Return(Game{ID: 1}, nil).
+		On("Patch",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }),
+			mock.Anything, mock.Anything).
+		Return(nil, api.ErrNotFound).
+		On("Restore", int64(1)).
+		Return(Game{ID: 1}, nil).
+		On("Restore", int64(2)).
+		Return(nil, api.ErrInvalidState).
+		On("Restore",
+			mock.MatchedBy(func(id int64) bool { return id > 2 })).
+		Return(nil, api.ErrNotFound)
 	controller := NewController(gr)
 
 	r := chi.NewMux()
 	r.Get("/{id}", api.HandlerFunc(controller.FindByID))
+	r.Get("/{id}/tree", api.HandlerFunc(controller.FindTreeByID))
 	r.Get("/", api.HandlerFunc(controller.List))
 	r.Post("/", api.HandlerFunc(controller.Create))
 	r.Delete("/{id}", api.HandlerFunc(controller.Delete))
 	r.Put("/{id}", api.HandlerFunc(controller.Update))
+	r.Patch("/{id}", api.HandlerFunc(controller.Patch))
+	r.Post("/{id}/start", api.HandlerFunc(controller.Start))
+	r.Post("/{id}/pause", api.HandlerFunc(controller.Pause))
+	r.Post("/{id}/close", api.HandlerFunc(controller.Close))
+	r.Put("/{id}/winners", api.HandlerFunc(controller.SetWinners))
+	r.Post("/{id}/players", api.HandlerFunc(controller.AddPlayers))
+	r.Delete("/{id}/players/{accountId}", api.HandlerFunc(controller.RemovePlayer))
+	r.Post("/{id}/restore", api.HandlerFunc(controller.Restore))
 
 	suite.tServer = httptest.NewServer(r)
 }
@@ -107,7 +182,12 @@ func (suite *ControllerSuite) TestCreate() {
 		{
 			Name:           "T00-05-GameCreated",
 			ExpectedStatus: http.StatusCreated,
-			Body:           `{"playersCount": 10}`,
+			Body:           `{"name": "test"}`,
+		},
+		{
+			Name:           "T00-56-UnknownDifficulty",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"name": "test", "difficulty": "impossible"}`,
 		},
 	}
 	for _, tc := range tcs {
@@ -299,6 +379,623 @@ func (suite *ControllerSuite) TestList() {
 
 }
 
+func (suite *ControllerSuite) TestTransition() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Id             string
+		Action         string
+	}{
+		{
+			Name:           "T00-13-GameStarted",
+			ExpectedStatus: http.StatusOK,
+			Id:             "1",
+			Action:         "start",
+		},
+		{
+			Name:           "T00-14-IllegalTransition",
+			ExpectedStatus: http.StatusConflict,
+			Id:             "1",
+			Action:         "close",
+		},
+		{
+			Name:           "T00-15-GameNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Id:             "14",
+			Action:         "pause",
+		},
+		{
+			Name:           "T00-16-BadId",
+			ExpectedStatus: http.StatusBadRequest,
+			Id:             "1a",
+			Action:         "start",
+		},
+		{
+			Name:           "T00-17-GameClosedWithWinners",
+			ExpectedStatus: http.StatusOK,
+			Id:             "1",
+			Action:         "close?deriveWinners=true",
+		},
+		{
+			Name:           "T00-18-BadDeriveWinners",
+			ExpectedStatus: http.StatusBadRequest,
+			Id:             "1",
+			Action:         "close?deriveWinners=maybe",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s/%s", suite.tServer.URL, tc.Id, tc.Action)
+			res, err := http.Post(url, "application/json", nil)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestSetWinners() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T00-19-WinnersSet",
+			ExpectedStatus: http.StatusOK,
+			Body:           `{"winners": ["a"]}`,
+			Id:             "1",
+		},
+		{
+			Name:           "T00-20-NotAPlayer",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"winners": ["b"]}`,
+			Id:             "1",
+		},
+		{
+			Name:           "T00-21-InvalidJSON",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"winners": ["a"]`,
+			Id:             "1",
+		},
+		{
+			Name:           "T00-22-GameNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"winners": ["a"]}`,
+			Id:             "14",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s/winners", suite.tServer.URL, tc.Id)
+			req, err := http.NewRequest(http.MethodPut,
+				url,
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestAddPlayers() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T00-23-PlayersAdded",
+			ExpectedStatus: http.StatusOK,
+			Body:           `{"players": ["a"]}`,
+			Id:             "1",
+		},
+		{
+			Name:           "T00-24-PlayerAlreadyInGame",
+			ExpectedStatus: http.StatusConflict,
+			Body:           `{"players": ["b"]}`,
+			Id:             "1",
+		},
+		{
+			Name:           "T00-25-GameNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"players": ["a"]}`,
+			Id:             "14",
+		},
+		{
+			Name:           "T00-26-InvalidJSON",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"players": "a"}`,
+			Id:             "1",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s/players", suite.tServer.URL, tc.Id)
+			res, err := http.Post(url, "application/json", bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestRemovePlayer() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Id             string
+		AccountId      string
+	}{
+		{
+			Name:           "T00-27-PlayerRemoved",
+			ExpectedStatus: http.StatusNoContent,
+			Id:             "1",
+			AccountId:      "a",
+		},
+		{
+			Name:           "T00-28-PlayerNotInGame",
+			ExpectedStatus: http.StatusNotFound,
+			Id:             "1",
+			AccountId:      "b",
+		},
+		{
+			Name:           "T00-29-GameClosed",
+			ExpectedStatus: http.StatusConflict,
+			Id:             "2",
+			AccountId:      "a",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s/players/%s", suite.tServer.URL, tc.Id, tc.AccountId)
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			suite.NoError(err)
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestListFilters() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Query          string
+	}{
+		{
+			Name:           "T00-30-AllFilters",
+			ExpectedStatus: http.StatusOK,
+			Query:          "difficulty=easy&status=open&name=test&startedAtFrom=2023-01-01&closedAtTo=2023-02-01&sort=startedAt:asc",
+		},
+		{
+			Name:           "T00-31-StatusClosed",
+			ExpectedStatus: http.StatusOK,
+			Query:          "status=closed&sort=name",
+		},
+		{
+			Name:           "T00-32-InvalidStatus",
+			ExpectedStatus: http.StatusBadRequest,
+			Query:          "status=finished",
+		},
+		{
+			Name:           "T00-33-InvalidStartedAt",
+			ExpectedStatus: http.StatusBadRequest,
+			Query:          "startedAtFrom=yesterday",
+		},
+		{
+			Name:           "T00-34-UnsupportedSortField",
+			ExpectedStatus: http.StatusBadRequest,
+			Query:          "sort=players:asc",
+		},
+		{
+			Name:           "T00-35-InvalidSortDirection",
+			ExpectedStatus: http.StatusBadRequest,
+			Query:          "sort=name:up",
+		},
+		{
+			Name:           "T00-47-DeletedGames",
+			ExpectedStatus: http.StatusOK,
+			Query:          "deleted=true",
+		},
+		{
+			Name:           "T00-48-InvalidDeleted",
+			ExpectedStatus: http.StatusBadRequest,
+			Query:          "deleted=maybe",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s?%s", suite.tServer.URL, tc.Query))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestUnknownStatus() {
+	b, err := json.Marshal(Game{Status: Status(42)})
+	suite.NoError(err, "T00-62-UnknownStatus")
+	suite.Contains(string(b), `"status":"unknown"`, "T00-62-UnknownStatus")
+}
+
+func (suite *ControllerSuite) TestListCursor() {
+	cursor := api.Cursor{CreatedAt: time.Now(), ID: 3}
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Query          string
+		ExpectedLinks  []string
+		NoLinks        bool
+	}{
+		{
+			Name:           "T00-36-NextPage",
+			ExpectedStatus: http.StatusOK,
+			Query:          "pageSize=10&cursor=" + cursor.String(),
+			ExpectedLinks:  []string{`rel="next"`, `rel="prev"`},
+		},
+		{
+			Name:           "T00-37-MalformedCursor",
+			ExpectedStatus: http.StatusBadRequest,
+			Query:          "cursor=abc",
+		},
+		{
+			Name:           "T00-38-CursorWithUnsupportedSort",
+			ExpectedStatus: http.StatusBadRequest,
+			Query:          "sort=name&cursor=" + cursor.String(),
+		},
+		{
+			Name:           "T00-61-NoCursorWithUnsupportedSort",
+			ExpectedStatus: http.StatusOK,
+			Query:          "sort=updatedAt&pageSize=10",
+			NoLinks:        true,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s?%s", suite.tServer.URL, tc.Query))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+
+			for _, link := range tc.ExpectedLinks {
+				suite.Contains(res.Header.Get("Link"), link, tc.Name)
+			}
+
+			if tc.ExpectedStatus != http.StatusOK {
+				return
+			}
+
+			if tc.NoLinks {
+				var body api.PaginatedResponse
+				suite.NoError(json.NewDecoder(res.Body).Decode(&body))
+				suite.Empty(res.Header.Get("Link"), tc.Name)
+				suite.Empty(body.Metadata.Next, tc.Name)
+				suite.True(body.Metadata.HasNext, "pages can still be reached by number")
+				return
+			}
+
+			var body api.PaginatedResponse
+			suite.NoError(json.NewDecoder(res.Body).Decode(&body))
+			suite.Len(body.Data, 10, tc.Name)
+			suite.NotEmpty(body.Metadata.Next, tc.Name)
+			suite.NotEmpty(body.Metadata.Prev, tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestFindTree() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Path           string
+	}{
+		{
+			Name:           "T00-39-TreeExists",
+			ExpectedStatus: http.StatusOK,
+			Path:           "1/tree",
+		},
+		{
+			Name:           "T00-40-TreeNotExists",
+			ExpectedStatus: http.StatusNotFound,
+			Path:           "12/tree",
+		},
+		{
+			Name:           "T00-41-IncludeTree",
+			ExpectedStatus: http.StatusOK,
+			Path:           "1?include=rounds.turns",
+		},
+		{
+			Name:           "T00-42-UnsupportedInclude",
+			ExpectedStatus: http.StatusBadRequest,
+			Path:           "1?include=players",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Path))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+
+			if tc.ExpectedStatus != http.StatusOK {
+				return
+			}
+
+			var body map[string]any
+			suite.NoError(json.NewDecoder(res.Body).Decode(&body))
+			suite.Contains(body, "rounds", tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestRestore() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Id             string
+	}{
+		{
+			Name:           "T00-43-GameRestored",
+			ExpectedStatus: http.StatusOK,
+			Id:             "1",
+		},
+		{
+			Name:           "T00-44-GameNotDeleted",
+			ExpectedStatus: http.StatusConflict,
+			Id:             "2",
+		},
+		{
+			Name:           "T00-45-GameNotExists",
+			ExpectedStatus: http.StatusNotFound,
+			Id:             "12",
+		},
+		{
+			Name:           "T00-46-BadId",
+			ExpectedStatus: http.StatusBadRequest,
+			Id:             "a",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Post(fmt.Sprintf("%s/%s/restore", suite.tServer.URL, tc.Id), "", nil)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestFindTreeConditional() {
+	res, err := http.Get(suite.tServer.URL + "/1/tree")
+	suite.Require().NoError(err)
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+	suite.NotEmpty(etag)
+
+	req, err := http.NewRequest(http.MethodGet, suite.tServer.URL+"/1/tree", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("If-None-Match", etag)
+
+	res, err = http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer res.Body.Close()
+	suite.Equal(http.StatusNotModified, res.StatusCode, "T00-63-TreeNotModified")
+}
+
+func (suite *ControllerSuite) TestConditional() {
+	res, err := http.Get(suite.tServer.URL + "/1")
+	suite.NoError(err)
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+	suite.NotEmpty(etag)
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Method         string
+		Header         string
+		Value          string
+		Body           string
+	}{
+		{
+			Name:           "T00-49-NotModified",
+			ExpectedStatus: http.StatusNotModified,
+			Method:         http.MethodGet,
+			Header:         "If-None-Match",
+			Value:          etag,
+		},
+		{
+			Name:           "T00-50-Modified",
+			ExpectedStatus: http.StatusOK,
+			Method:         http.MethodGet,
+			Header:         "If-None-Match",
+			Value:          `"stale"`,
+		},
+		{
+			Name:           "T00-51-PreconditionFailed",
+			ExpectedStatus: http.StatusPreconditionFailed,
+			Method:         http.MethodPut,
+			Header:         "If-Match",
+			Value:          `"stale"`,
+			Body:           `{"currentRound": 10, "name": "test"}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, suite.tServer.URL+"/1", bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			req.Header.Set(tc.Header, tc.Value)
+
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+
+			if tc.ExpectedStatus == http.StatusOK {
+				suite.Equal(etag, res.Header.Get("ETag"), tc.Name)
+			}
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestPatch() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T00-52-GamePatched",
+			ExpectedStatus: http.StatusOK,
+			Body:           `{"name": "test", "description": null}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T00-53-NullName",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"name": null}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T00-54-GameNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"description": null}`,
+			Id:             `11`,
+		},
+		{
+			Name:           "T00-55-InvalidJSON",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"name": }`,
+			Id:             `1`,
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Id)
+			req, err := http.NewRequest(http.MethodPatch,
+				url,
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestValidation() {
+
+	tcs := []struct {
+		Name   string
+		Body   string
+		Fields []string
+	}{
+		{
+			Name:   "T00-57-MissingName",
+			Body:   `{"description": "test"}`,
+			Fields: []string{"name"},
+		},
+		{
+			Name:   "T00-58-ManyViolations",
+			Body:   `{"name": " ", "difficulty": "impossible", "players": ["a", "", "a"]}`,
+			Fields: []string{"name", "difficulty", "players[1]", "players[2]"},
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Post(suite.tServer.URL,
+				"application/json",
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			defer res.Body.Close()
+			suite.Equal(http.StatusBadRequest, res.StatusCode, tc.Name)
+
+			suite.Equal(api.ProblemContentType, res.Header.Get("Content-Type"), tc.Name)
+
+			var body api.Problem
+			suite.NoError(json.NewDecoder(res.Body).Decode(&body))
+			suite.Equal(api.CodeValidationFailed, body.Code, tc.Name)
+
+			fields := make([]string, len(body.Errors))
+			for i, e := range body.Errors {
+				fields[i] = e.Field
+			}
+			suite.Equal(tc.Fields, fields, tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestProblem() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		ExpectedCode   api.Code
+		Arg            string
+	}{
+		{
+			Name:           "T00-59-NotFound",
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCode:   api.CodeNotFound,
+			Arg:            "12",
+		},
+		{
+			Name:           "T00-60-BadID",
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   api.CodeInvalidParam,
+			Arg:            "aaa",
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Arg))
+			suite.NoError(err)
+			defer res.Body.Close()
+			suite.Equal(api.ProblemContentType, res.Header.Get("Content-Type"), tc.Name)
+
+			var body api.Problem
+			suite.NoError(json.NewDecoder(res.Body).Decode(&body))
+			suite.Equal(tc.ExpectedStatus, body.Status, tc.Name)
+			suite.Equal(tc.ExpectedCode, body.Code, tc.Name)
+			suite.Equal("urn:game-repository:problem:"+string(tc.ExpectedCode), body.Type, tc.Name)
+			suite.Equal("/"+tc.Arg, body.Instance, tc.Name)
+		})
+	}
+}
+
 func TestControllerSuite(t *testing.T) {
 	suite.Run(t, new(ControllerSuite))
 }
@@ -337,8 +1034,8 @@ func (gr *MockedRepository) Delete(id int64) error {
 	return args.Error(0)
 }
 
-func (gr *MockedRepository) Update(id int64, ur *UpdateRequest) (Game, error) {
-	args := gr.Called(id, ur)
+func (gr *MockedRepository) Update(id int64, ur *UpdateRequest, p api.Precondition) (Game, error) {
+	args := gr.Called(id, ur, p)
 	v := args.Get(0)
 
 	if v == nil {
@@ -347,8 +1044,8 @@ func (gr *MockedRepository) Update(id int64, ur *UpdateRequest) (Game, error) {
 	return v.(Game), args.Error(1)
 }
 
-func (gr *MockedRepository) FindByInterval(accountId string, i api.IntervalParams, p api.PaginationParams) ([]Game, int64, error) {
-	args := gr.Called(i, p)
+func (gr *MockedRepository) FindByFilter(f *Filter, p api.PaginationParams) ([]Game, int64, error) {
+	args := gr.Called(f, p)
 	v := args.Get(0)
 
 	if v == nil {
@@ -357,3 +1054,72 @@ func (gr *MockedRepository) FindByInterval(accountId string, i api.IntervalParam
 	return v.([]Game), int64(args.Int(1)), args.Error(2)
 
 }
+
+func (gr *MockedRepository) Transition(id int64, to Status) (Game, error) {
+	args := gr.Called(id, to)
+	v := args.Get(0)
+	if v == nil {
+		return Game{}, args.Error(1)
+	}
+	return v.(Game), args.Error(1)
+}
+
+func (gr *MockedRepository) Close(id int64, deriveWinners bool) (Game, error) {
+	args := gr.Called(id, deriveWinners)
+	v := args.Get(0)
+	if v == nil {
+		return Game{}, args.Error(1)
+	}
+	return v.(Game), args.Error(1)
+}
+
+func (gr *MockedRepository) SetWinners(id int64, r *WinnersRequest) (Game, error) {
+	args := gr.Called(id, r)
+	v := args.Get(0)
+	if v == nil {
+		return Game{}, args.Error(1)
+	}
+	return v.(Game), args.Error(1)
+}
+
+func (gr *MockedRepository) AddPlayers(id int64, r *PlayersRequest) (Game, error) {
+	args := gr.Called(id, r)
+	v := args.Get(0)
+	if v == nil {
+		return Game{}, args.Error(1)
+	}
+	return v.(Game), args.Error(1)
+}
+
+func (gr *MockedRepository) RemovePlayer(id int64, accountId string) error {
+	args := gr.Called(id, accountId)
+	return args.Error(0)
+}
+
+func (gr *MockedRepository) FindTreeById(id int64) (Tree, error) {
+	args := gr.Called(id)
+	v := args.Get(0)
+	if v == nil {
+		return Tree{}, args.Error(1)
+	}
+	return v.(Tree), args.Error(1)
+}
+
+func (gr *MockedRepository) Restore(id int64) (Game, error) {
+	args := gr.Called(id)
+	v := args.Get(0)
+	if v == nil {
+		return Game{}, args.Error(1)
+	}
+	return v.(Game), args.Error(1)
+}
+
+func (gr *MockedRepository) Patch(id int64, request *PatchRequest, p api.Precondition) (Game, error) {
+	args := gr.Called(id, request, p)
+	v := args.Get(0)
+
+	if v == nil {
+		return Game{}, args.Error(1)
+	}
+	return v.(Game), args.Error(1)
+}
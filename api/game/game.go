@@ -1,55 +1,311 @@
 package game
 
 import (
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alarmfox/game-repository/api"
 	"github.com/alarmfox/game-repository/model"
 )
 
 type Game struct {
-	ID           int64      `json:"id"`
-	CurrentRound int        `json:"currentRound"`
-	Description  string     `json:"description"`
-	Difficulty   string     `json:"difficulty"`
-	CreatedAt    time.Time  `json:"createdAt"`
-	UpdatedAt    time.Time  `json:"updatedAt"`
-	StartedAt    *time.Time `json:"startedAt"`
-	ClosedAt     *time.Time `json:"closedAt"`
-	Name         string     `json:"name"`
-	Players      []Player   `json:"players,omitempty"`
+	ID             int64      `json:"id"`
+	CurrentRound   int        `json:"currentRound"`
+	CurrentRoundID *int64     `json:"currentRoundId"`
+	Description    string     `json:"description"`
+	Difficulty     string     `json:"difficulty"`
+	Status         Status     `json:"status"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	StartedAt      *time.Time `json:"startedAt"`
+	ClosedAt       *time.Time `json:"closedAt"`
+	RoundTimeLimit *int64     `json:"roundTimeLimit"`
+	DeletedAt      *time.Time `json:"deletedAt,omitempty"`
+	Name           string     `json:"name"`
+	Players        []Player   `json:"players,omitempty"`
+}
+
+// Tree is a game with its rounds and their turns
+type Tree struct {
+	Game
+	Rounds []TreeRound `json:"rounds"`
+}
+
+// LastModified is the latest update time of the game, its rounds and turns
+func (t Tree) LastModified() time.Time {
+	last := t.UpdatedAt
+	for _, round := range t.Rounds {
+		if round.UpdatedAt.After(last) {
+			last = round.UpdatedAt
+		}
+		for _, turn := range round.Turns {
+			if turn.UpdatedAt.After(last) {
+				last = turn.UpdatedAt
+			}
+		}
+	}
+	return last
+}
+
+type TreeRound struct {
+	ID          int64      `json:"id"`
+	Order       int        `json:"order"`
+	TestClassId string     `json:"testClassId"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	StartedAt   *time.Time `json:"startedAt"`
+	ClosedAt    *time.Time `json:"closedAt"`
+	TimeLimit   *int64     `json:"timeLimit"`
+	Turns       []TreeTurn `json:"turns"`
+}
+
+type TreeTurn struct {
+	ID        int64         `json:"id"`
+	PlayerID  int64         `json:"playerId"`
+	IsWinner  bool          `json:"isWinner"`
+	Scores    *model.Scores `json:"scores"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	StartedAt *time.Time    `json:"startedAt"`
+	ClosedAt  *time.Time    `json:"closedAt"`
+	Forfeited bool          `json:"forfeited"`
+	HasFile   bool          `json:"hasFile"`
 }
 
 type Player struct {
 	ID        int64  `json:"id"`
 	AccountID string `json:"accountId"`
+	IsWinner  bool   `json:"isWinner"`
 }
 
 type CreateRequest struct {
-	Name        string     `json:"name"`
-	Players     []string   `json:"players"`
-	Description string     `json:"description"`
-	Difficulty  string     `json:"difficulty"`
-	StartedAt   *time.Time `json:"startedAt,omitempty"`
-	ClosedAt    *time.Time `json:"closedAt,omitempty"`
+	Name           string   `json:"name"`
+	Players        []string `json:"players"`
+	Description    string   `json:"description"`
+	Difficulty     string   `json:"difficulty"`
+	RoundTimeLimit *int64   `json:"roundTimeLimit,omitempty"`
 }
 
-func (CreateRequest) Validate() error {
-	return nil
+const (
+	maxNameLength        = 255
+	maxDescriptionLength = 1024
+)
+
+func (r CreateRequest) Validate() error {
+	return api.Validate(
+		api.Required("name", r.Name),
+		api.MaxLength("name", r.Name, maxNameLength),
+		api.MaxLength("description", r.Description, maxDescriptionLength),
+		api.If(r.Difficulty != "", api.Difficulty("difficulty", r.Difficulty)),
+		api.Each("players", r.Players, api.Required),
+		api.Unique("players", r.Players),
+		api.Optional("roundTimeLimit", r.RoundTimeLimit, api.Positive[int64]),
+	)
 }
 
 type UpdateRequest struct {
-	CurrentRound int        `json:"currentRound"`
-	Name         string     `json:"name"`
-	Description  string     `json:"description"`
-	StartedAt    *time.Time `json:"startedAt,omitempty"`
-	ClosedAt     *time.Time `json:"closedAt,omitempty"`
+	CurrentRound   int    `json:"currentRound"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	RoundTimeLimit *int64 `json:"roundTimeLimit,omitempty"`
+}
+
+func (r UpdateRequest) Validate() error {
+	return api.Validate(
+		api.If(r.CurrentRound != 0, api.Positive("currentRound", r.CurrentRound)),
+		api.MaxLength("name", r.Name, maxNameLength),
+		api.MaxLength("description", r.Description, maxDescriptionLength),
+		api.Optional("roundTimeLimit", r.RoundTimeLimit, api.Positive[int64]),
+	)
+}
+
+// PatchRequest is a JSON merge patch (RFC 7396) of a game. Null description
+// and round time limit are cleared; the other fields cannot be null.
+type PatchRequest struct {
+	CurrentRound   api.Nullable[int]    `json:"currentRound"`
+	Name           api.Nullable[string] `json:"name"`
+	Description    api.Nullable[string] `json:"description"`
+	RoundTimeLimit api.Nullable[int64]  `json:"roundTimeLimit"`
 }
 
-func (UpdateRequest) Validate() error {
+func (r PatchRequest) Validate() error {
+	return api.Validate(
+		r.CurrentRound.NotNull("currentRound"),
+		api.If(r.CurrentRound.Present(), api.Positive("currentRound", r.CurrentRound.Value)),
+		r.Name.NotNull("name"),
+		api.If(r.Name.Present(),
+			api.Required("name", r.Name.Value),
+			api.MaxLength("name", r.Name.Value, maxNameLength)),
+		api.MaxLength("description", r.Description.Value, maxDescriptionLength),
+		api.If(r.RoundTimeLimit.Present(), api.Positive("roundTimeLimit", r.RoundTimeLimit.Value)),
+	)
+}
+
+func (r *PatchRequest) updates() map[string]any {
+	updates := make(map[string]any)
+	r.CurrentRound.Apply(updates, "current_round")
+	r.Name.Apply(updates, "name")
+	r.Description.Apply(updates, "description")
+	r.RoundTimeLimit.Apply(updates, "round_time_limit")
+	return updates
+}
+
+// Status is the lifecycle state of a game. A game is created, can be started,
+// paused and resumed any number of times and is eventually closed. Closed
+// games cannot be reopened.
+type Status model.GameStatus
+
+const (
+	created = Status(model.GameStatusCreated)
+	started = Status(model.GameStatusStarted)
+	paused  = Status(model.GameStatusPaused)
+	closed  = Status(model.GameStatusClosed)
+)
+
+// transitions lists, for every status, the statuses a game can move to
+var transitions = map[Status][]Status{
+	created: {started, closed},
+	started: {paused, closed},
+	paused:  {started, closed},
+	closed:  {},
+}
+
+func (s Status) CanTransition(to Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (Status) Parse(s string) (Status, error) {
+	switch strings.ToLower(s) {
+	case created.String():
+		return created, nil
+	case started.String():
+		return started, nil
+	case paused.String():
+		return paused, nil
+	case closed.String():
+		return closed, nil
+	default:
+		return Status(0), fmt.Errorf("%w: unsupported game status",
+			api.ErrInvalidParam)
+	}
+}
+
+// String names the status. Values unknown to this version, as read from a
+// database written by a newer one, are named unknown rather than failing the
+// encoding of whole lists.
+func (s Status) String() string {
+	switch s {
+	case created:
+		return "created"
+	case started:
+		return "started"
+	case paused:
+		return "paused"
+	case closed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+func (s Status) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	st, err := s.Parse(v)
+	if err != nil {
+		return err
+	}
+	*s = st
 	return nil
 }
 
+func (s Status) AsModel() model.GameStatus {
+	return model.GameStatus(s)
+}
+
+type WinnersRequest struct {
+	Winners []string `json:"winners"`
+}
+
+func (r WinnersRequest) Validate() error {
+	return api.Validate(
+		api.Each("winners", r.Winners, api.Required),
+		api.Unique("winners", r.Winners),
+	)
+}
+
+type PlayersRequest struct {
+	Players []string `json:"players"`
+}
+
+func (r PlayersRequest) Validate() error {
+	return api.Validate(
+		api.NotEmpty("players", r.Players),
+		api.Each("players", r.Players, api.Required),
+		api.Unique("players", r.Players),
+	)
+}
+
+// Filter collects the criteria used to list games
+type Filter struct {
+	AccountID  string
+	Difficulty string
+	Name       string
+	Status     StatusFilter
+	CreatedAt  api.IntervalParams
+	StartedAt  api.IntervalParams
+	ClosedAt   api.IntervalParams
+	Sort       api.SortParams
+	// Deleted lists soft deleted games instead of live ones
+	Deleted bool
+}
+
+// sortColumns maps the fields accepted by the sort parameter to columns
+var sortColumns = map[string]string{
+	"id":         "games.id",
+	"name":       "games.name",
+	"difficulty": "games.difficulty",
+	"createdAt":  "games.created_at",
+	"updatedAt":  "games.updated_at",
+	"startedAt":  "games.started_at",
+	"closedAt":   "games.closed_at",
+}
+
+// StatusFilter is either a game status or "open", which matches every game
+// that is not closed
+type StatusFilter string
+
+const statusOpen StatusFilter = "open"
+
+func (StatusFilter) Parse(s string) (StatusFilter, error) {
+	s = strings.ToLower(s)
+	if StatusFilter(s) == statusOpen {
+		return statusOpen, nil
+	}
+	if _, err := Status(0).Parse(s); err != nil {
+		return "", err
+	}
+	return StatusFilter(s), nil
+}
+
+func (s StatusFilter) AsString() string {
+	return string(s)
+}
+
 type KeyType int64
 
 func (c KeyType) Parse(s string) (KeyType, error) {
@@ -72,6 +328,40 @@ func (k IntervalType) AsTime() time.Time {
 	return time.Time(k)
 }
 
+type CustomString string
+
+func (CustomString) Parse(s string) (CustomString, error) {
+	return CustomString(s), nil
+}
+
+func (s CustomString) AsString() string {
+	return string(s)
+}
+
+// IncludeType lists the associations to embed in a game. Only the whole tree
+// of rounds and turns is supported.
+type IncludeType string
+
+const includeTree IncludeType = "rounds.turns"
+
+func (IncludeType) Parse(s string) (IncludeType, error) {
+	if IncludeType(s) != includeTree {
+		return "", fmt.Errorf("%w: supported value is %s", api.ErrInvalidParam, includeTree)
+	}
+	return includeTree, nil
+}
+
+type BoolType bool
+
+func (BoolType) Parse(s string) (BoolType, error) {
+	b, err := strconv.ParseBool(s)
+	return BoolType(b), err
+}
+
+func (b BoolType) AsBool() bool {
+	return bool(b)
+}
+
 type AccountIdType string
 
 func (AccountIdType) Parse(s string) (AccountIdType, error) {
@@ -82,19 +372,64 @@ func (a AccountIdType) AsString() string {
 	return string(a)
 }
 func fromModel(g *model.Game) Game {
-	return Game{
-		ID:           g.ID,
-		CurrentRound: g.CurrentRound,
-		Difficulty:   g.Difficulty,
-		Description:  g.Description.String,
-		CreatedAt:    g.CreatedAt,
-		UpdatedAt:    g.UpdatedAt,
-		Name:         g.Name,
-		StartedAt:    g.StartedAt,
-		ClosedAt:     g.ClosedAt,
-		Players:      parsePlayers(g.Players),
+	game := Game{
+		ID:             g.ID,
+		CurrentRound:   g.CurrentRound,
+		CurrentRoundID: g.CurrentRoundID,
+		Difficulty:     g.Difficulty,
+		Description:    g.Description.String,
+		Status:         Status(g.Status),
+		CreatedAt:      g.CreatedAt,
+		UpdatedAt:      g.UpdatedAt,
+		Name:           g.Name,
+		StartedAt:      g.StartedAt,
+		ClosedAt:       g.ClosedAt,
+		RoundTimeLimit: g.RoundTimeLimit,
+		Players:        parsePlayers(g.Players),
+	}
+
+	if g.DeletedAt.Valid {
+		game.DeletedAt = &g.DeletedAt.Time
 	}
 
+	return game
+}
+
+func treeFromModel(g *model.Game) Tree {
+	rounds := make([]TreeRound, len(g.Rounds))
+	for i, r := range g.Rounds {
+		turns := make([]TreeTurn, len(r.Turns))
+		for j, t := range r.Turns {
+			turns[j] = TreeTurn{
+				ID:        t.ID,
+				PlayerID:  t.PlayerID,
+				IsWinner:  t.IsWinner,
+				Scores:    t.Scores,
+				CreatedAt: t.CreatedAt,
+				UpdatedAt: t.UpdatedAt,
+				StartedAt: t.StartedAt,
+				ClosedAt:  t.ClosedAt,
+				Forfeited: t.Forfeited,
+				HasFile:   len(t.Files) > 0,
+			}
+		}
+		rounds[i] = TreeRound{
+			ID:          r.ID,
+			Order:       r.Order,
+			TestClassId: r.TestClassId,
+			CreatedAt:   r.CreatedAt,
+			UpdatedAt:   r.UpdatedAt,
+			StartedAt:   r.StartedAt,
+			ClosedAt:    r.ClosedAt,
+			TimeLimit:   r.TimeLimit,
+			Turns:       turns,
+		}
+	}
+
+	return Tree{
+		Game:   fromModel(g),
+		Rounds: rounds,
+	}
 }
 
 func parsePlayers(players []model.Player) []Player {
@@ -107,3 +442,18 @@ func parsePlayers(players []model.Player) []Player {
 	}
 	return res
 }
+
+func cursorOf(g Game) api.Cursor {
+	return api.Cursor{CreatedAt: g.CreatedAt, ID: g.ID}
+}
+
+func markWinners(players []Player, playerGames []model.PlayerGame) {
+	winners := make(map[string]bool, len(playerGames))
+	for _, pg := range playerGames {
+		winners[pg.PlayerID] = pg.IsWinner
+	}
+
+	for i := range players {
+		players[i].IsWinner = winners[players[i].AccountID]
+	}
+}
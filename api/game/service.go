@@ -1,9 +1,14 @@
 package game
 
 import (
+	"database/sql"
+	"fmt"
+	"time"
+
 	"github.com/alarmfox/game-repository/api"
 	"github.com/alarmfox/game-repository/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
@@ -19,10 +24,11 @@ func NewRepository(db *gorm.DB) *Repository {
 func (gs *Repository) Create(r *CreateRequest) (Game, error) {
 	var (
 		game = model.Game{
-			Name:      r.Name,
-			StartedAt: r.StartedAt,
-			ClosedAt:  r.ClosedAt,
-			Players:   make([]model.Player, len(r.Players)),
+			Name:           r.Name,
+			Description:    sql.NullString{String: r.Description, Valid: r.Description != ""},
+			Difficulty:     r.Difficulty,
+			RoundTimeLimit: r.RoundTimeLimit,
+			Players:        make([]model.Player, len(r.Players)),
 		}
 	)
 	// detect duplication in player
@@ -49,72 +55,628 @@ func (gs *Repository) Create(r *CreateRequest) (Game, error) {
 }
 
 func (gs *Repository) FindById(id int64) (Game, error) {
-	var game model.Game
-	err := gs.db.
-		Preload("Players").
-		First(&game, id).
-		Error
-
-	return fromModel(&game), api.MakeServiceError(err)
+	game, err := findGame(gs.db, id)
+	return game, api.MakeServiceError(err)
 }
 
-func (gs *Repository) FindByInterval(accountId string, i api.IntervalParams, p api.PaginationParams) ([]Game, int64, error) {
+func (gs *Repository) FindByFilter(f *Filter, p api.PaginationParams) ([]Game, int64, error) {
 	var (
 		games []model.Game
 		n     int64
-		err   error
 	)
 
-	if accountId != "" {
-		err = gs.db.Transaction(func(tx *gorm.DB) error {
-			association := tx.Model(&model.Player{AccountID: accountId}).
-				Scopes(api.WithInterval(i, "games.created_at"),
-					api.WithPagination(p)).
-				Order("games.created_at desc").
-				Association("Games")
-
-			n = association.Count()
-			return association.Find(&games)
-
-		})
-	} else {
-		err = gs.db.Scopes(api.WithInterval(i, "games.created_at"),
-			api.WithPagination(p)).
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.
+			Model(&model.Game{}).
+			Scopes(withFilter(f))
+
+		if p.Cursor != nil {
+			return query.
+				Scopes(api.WithCursor(p, "games", f.Sort.Desc),
+					withCurrentRoundId).
+				Find(&games).
+				Error
+		}
+
+		if err := query.Count(&n).Error; err != nil {
+			return err
+		}
+
+		// games with the same value of the sort field keep their relative
+		// order across pages
+		return query.
+			Scopes(api.WithSort(f.Sort, sortColumns),
+				api.WithPagination(p),
+				withCurrentRoundId).
+			Order(clause.OrderByColumn{
+				Column: clause.Column{Name: "games.id", Raw: true},
+				Desc:   f.Sort.Desc,
+			}).
 			Find(&games).
-			Count(&n).
 			Error
-	}
+	})
+
 	res := make([]Game, len(games))
 	for i, game := range games {
 		res[i] = fromModel(&game)
 	}
+
 	return res, n, api.MakeServiceError(err)
 }
 
 func (gs *Repository) Delete(id int64) error {
-	db := gs.db.
-		Where(&model.Game{ID: id}).
-		Delete(&model.Game{})
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		db := tx.
+			Model(&model.Game{}).
+			Where(&model.Game{ID: id}).
+			Update("deleted_at", now)
+		if db.Error != nil {
+			return db.Error
+		} else if db.RowsAffected < 1 {
+			return api.ErrNotFound
+		}
+
+		// rounds and turns share the deletion time of the game so that a
+		// restore brings back only what was deleted with it
+		rounds := tx.
+			Model(&model.Round{}).
+			Select("id").
+			Where(&model.Round{GameID: id})
+
+		err := tx.
+			Model(&model.Turn{}).
+			Where("round_id in (?)", rounds).
+			Update("deleted_at", now).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Model(&model.Round{}).
+			Where(&model.Round{GameID: id}).
+			Update("deleted_at", now).
+			Error
+	})
+
+	return api.MakeServiceError(err)
+}
+
+func (gs *Repository) Restore(id int64) (Game, error) {
+	var game Game
+
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		var deleted model.Game
+		err := tx.
+			Unscoped().
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&deleted, id).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if !deleted.DeletedAt.Valid {
+			return fmt.Errorf("%w: game is not deleted", api.ErrInvalidState)
+		}
+
+		deletedAt := deleted.DeletedAt.Time
+		rounds := tx.
+			Unscoped().
+			Model(&model.Round{}).
+			Select("id").
+			Where(&model.Round{GameID: id})
+
+		err = tx.
+			Unscoped().
+			Model(&model.Turn{}).
+			Where("round_id in (?)", rounds).
+			Where("deleted_at = ?", deletedAt).
+			Update("deleted_at", nil).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Unscoped().
+			Model(&model.Round{}).
+			Where(&model.Round{GameID: id}).
+			Where("deleted_at = ?", deletedAt).
+			Update("deleted_at", nil).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Unscoped().
+			Model(&deleted).
+			Update("deleted_at", nil).
+			Error
+		if err != nil {
+			return err
+		}
+
+		game, err = findGame(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return Game{}, api.MakeServiceError(err)
+	}
+
+	return game, nil
+}
+
+func (gs *Repository) Update(id int64, r *UpdateRequest, p api.Precondition) (Game, error) {
+	return gs.update(id, r, p)
+}
+
+func (gs *Repository) Patch(id int64, r *PatchRequest, p api.Precondition) (Game, error) {
+	return gs.update(id, r.updates(), p)
+}
+
+// update applies updates, a struct or a column map, to the game when p is
+// satisfied and returns the game as stored
+func (gs *Repository) update(id int64, updates any, p api.Precondition) (Game, error) {
+	var game Game
+
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		var current model.Game
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&current, id).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if !p.Matches(api.ETag(current.UpdatedAt)) {
+			return api.ErrPreconditionFailed
+		}
+
+		if m, ok := updates.(map[string]any); !ok || len(m) > 0 {
+			if err := tx.Model(&current).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		game, err = findGame(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return Game{}, api.MakeServiceError(err)
+	}
+
+	return game, nil
+}
+
+func (gs *Repository) Transition(id int64, to Status) (Game, error) {
+	var game Game
+
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		if err := transition(tx, id, to); err != nil {
+			return err
+		}
+
+		var err error
+		game, err = findGame(tx, id)
+		return err
+	})
 
-	if db.Error != nil {
-		return api.MakeServiceError(db.Error)
-	} else if db.RowsAffected < 1 {
-		return api.ErrNotFound
+	if err != nil {
+		return Game{}, api.MakeServiceError(err)
 	}
+
+	return game, nil
+}
+
+func (gs *Repository) Close(id int64, deriveWinners bool) (Game, error) {
+	var game Game
+
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		if err := transition(tx, id, closed); err != nil {
+			return err
+		}
+
+		if deriveWinners {
+			winners, err := roundWinners(tx, id)
+			if err != nil {
+				return err
+			}
+
+			if err := setWinners(tx, id, winners); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		game, err = findGame(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return Game{}, api.MakeServiceError(err)
+	}
+
+	return game, nil
+}
+
+func (gs *Repository) SetWinners(id int64, r *WinnersRequest) (Game, error) {
+	var game Game
+
+	// detect duplication in winners
+	if api.Duplicated(r.Winners) {
+		return Game{}, fmt.Errorf("%w: duplicated winner", api.ErrInvalidParam)
+	}
+
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&model.Game{}, id).
+			Error
+		if err != nil {
+			return err
+		}
+
+		var n int64
+		err = tx.
+			Model(&model.PlayerGame{}).
+			Where(&model.PlayerGame{GameID: id}).
+			Where("player_id in ?", r.Winners).
+			Count(&n).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if n != int64(len(r.Winners)) {
+			return fmt.Errorf("%w: winners must be players of the game", api.ErrInvalidParam)
+		}
+
+		if err := setWinners(tx, id, r.Winners); err != nil {
+			return err
+		}
+
+		if err := touch(tx, id); err != nil {
+			return err
+		}
+
+		game, err = findGame(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return Game{}, api.MakeServiceError(err)
+	}
+
+	return game, nil
+}
+
+func (gs *Repository) AddPlayers(id int64, r *PlayersRequest) (Game, error) {
+	var game Game
+
+	if len(r.Players) == 0 {
+		return Game{}, fmt.Errorf("%w: empty player list", api.ErrInvalidParam)
+	}
+
+	// detect duplication in player
+	if api.Duplicated(r.Players) {
+		return Game{}, fmt.Errorf("%w: duplicated player", api.ErrInvalidParam)
+	}
+
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOpenGame(tx, id); err != nil {
+			return err
+		}
+
+		var n int64
+		err := tx.
+			Model(&model.PlayerGame{}).
+			Where(&model.PlayerGame{GameID: id}).
+			Where("player_id in ?", r.Players).
+			Count(&n).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if n > 0 {
+			return fmt.Errorf("%w: player is already in game", api.ErrDuplicatedKey)
+		}
+
+		players := make([]model.Player, len(r.Players))
+		playerGames := make([]model.PlayerGame, len(r.Players))
+		for i, player := range r.Players {
+			players[i] = model.Player{AccountID: player}
+			playerGames[i] = model.PlayerGame{PlayerID: player, GameID: id}
+		}
+
+		err = tx.
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&players).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(&playerGames).Error; err != nil {
+			return err
+		}
+
+		if err := touch(tx, id); err != nil {
+			return err
+		}
+
+		game, err = findGame(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return Game{}, api.MakeServiceError(err)
+	}
+
+	return game, nil
+}
+
+func (gs *Repository) RemovePlayer(id int64, accountId string) error {
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOpenGame(tx, id); err != nil {
+			return err
+		}
+
+		db := tx.
+			Where(&model.PlayerGame{GameID: id, PlayerID: accountId}).
+			Delete(&model.PlayerGame{})
+		if db.Error != nil {
+			return db.Error
+		} else if db.RowsAffected < 1 {
+			return api.ErrNotFound
+		}
+
+		if err := touch(tx, id); err != nil {
+			return err
+		}
+
+		// turns still in progress are dropped; closed ones are kept as history
+		return tx.
+			Where("player_id = (?)", tx.
+				Model(&model.Player{}).
+				Select("id").
+				Where(&model.Player{AccountID: accountId})).
+			Where("round_id in (?)", tx.
+				Model(&model.Round{}).
+				Select("id").
+				Where(&model.Round{GameID: id})).
+			Where("closed_at is null").
+			Delete(&model.Turn{}).
+			Error
+	})
+
+	return api.MakeServiceError(err)
+}
+
+func withFilter(f *Filter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f.Deleted {
+			db = db.
+				Unscoped().
+				Where("games.deleted_at is not null")
+		}
+
+		if f.AccountID != "" {
+			db = db.
+				Joins("join player_games on player_games.game_id = games.id").
+				Where("player_games.player_id = ?", f.AccountID)
+		}
+
+		switch f.Status {
+		case "":
+		case statusOpen:
+			db = db.Where("games.status <> ?", closed.AsModel())
+		default:
+			status, _ := Status(0).Parse(f.Status.AsString())
+			db = db.Where("games.status = ?", status.AsModel())
+		}
+
+		return db.Scopes(
+			api.WithInterval(f.CreatedAt, "games.created_at"),
+			api.WithTimeRange(f.StartedAt, "games.started_at"),
+			api.WithTimeRange(f.ClosedAt, "games.closed_at"),
+			api.WithEqual("games.difficulty", f.Difficulty),
+			api.WithSubstring("games.name", f.Name),
+		)
+	}
+}
+
+// withCurrentRoundId selects the games along with the identifier of their
+// current round
+func withCurrentRoundId(db *gorm.DB) *gorm.DB {
+	return db.Select("games.*, (select rounds.id from rounds where rounds.game_id = games.id " +
+		"and rounds.\"order\" = games.current_round and rounds.deleted_at is null) as current_round_id")
+}
+
+// lockOpenGame locks the game until the end of the transaction tx and fails
+// if the game is closed
+func lockOpenGame(tx *gorm.DB, id int64) error {
+	var game model.Game
+
+	err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&game, id).
+		Error
+	if err != nil {
+		return err
+	}
+
+	if game.Status == model.GameStatusClosed {
+		return fmt.Errorf("%w: game is closed", api.ErrInvalidState)
+	}
+
 	return nil
 }
 
-func (gs *Repository) Update(id int64, r *UpdateRequest) (Game, error) {
+func (gs *Repository) FindTreeById(id int64) (Tree, error) {
+	var (
+		game        model.Game
+		playerGames []model.PlayerGame
+	)
+
+	err := gs.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Scopes(withCurrentRoundId).
+			Preload("Players").
+			Preload("Rounds", func(db *gorm.DB) *gorm.DB {
+				return db.Order("\"order\" asc")
+			}).
+			Preload("Rounds.Turns", func(db *gorm.DB) *gorm.DB {
+				return db.Order("id asc")
+			}).
+			Preload("Rounds.Turns.Files", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id", "turn_id")
+			}).
+			First(&game, id).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Where(&model.PlayerGame{GameID: id}).
+			Find(&playerGames).
+			Error
+	})
+
+	if err != nil {
+		return Tree{}, api.MakeServiceError(err)
+	}
+
+	tree := treeFromModel(&game)
+	markWinners(tree.Players, playerGames)
 
+	return tree, nil
+}
+
+// touch bumps the update time of the game, and so its entity tag, when
+// players of the game change
+func touch(tx *gorm.DB, id int64) error {
+	return tx.
+		Model(&model.Game{}).
+		Where(&model.Game{ID: id}).
+		Update("updated_at", time.Now()).
+		Error
+}
+
+// findGame returns a game with its players and their winner flag
+func findGame(tx *gorm.DB, id int64) (Game, error) {
 	var (
-		game model.Game = model.Game{ID: id}
-		err  error
+		game        model.Game
+		playerGames []model.PlayerGame
 	)
 
-	err = gs.db.Model(&game).Updates(r).Error
+	err := tx.
+		Scopes(withCurrentRoundId).
+		Preload("Players").
+		First(&game, id).
+		Error
 	if err != nil {
-		return Game{}, api.MakeServiceError(err)
+		return Game{}, err
+	}
+
+	err = tx.
+		Where(&model.PlayerGame{GameID: id}).
+		Find(&playerGames).
+		Error
+	if err != nil {
+		return Game{}, err
+	}
+
+	g := fromModel(&game)
+	markWinners(g.Players, playerGames)
+
+	return g, nil
+}
+
+// transition moves the game to the status to. The game row is locked until
+// the end of the transaction tx.
+func transition(tx *gorm.DB, id int64, to Status) error {
+	var game model.Game
+
+	err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&game, id).
+		Error
+	if err != nil {
+		return err
+	}
+
+	from := Status(game.Status)
+	if !from.CanTransition(to) {
+		return fmt.Errorf("%w: cannot move game from %s to %s", api.ErrInvalidState, from, to)
+	}
+
+	now := time.Now()
+	updates := map[string]any{"status": to.AsModel()}
+	switch {
+	case to == started && game.StartedAt == nil:
+		updates["started_at"] = now
+	case to == closed:
+		updates["closed_at"] = now
 	}
 
-	return fromModel(&game), api.MakeServiceError(err)
+	return tx.Model(&game).Updates(updates).Error
+}
+
+// roundWinners returns the account ids of the players who won the highest
+// number of rounds in the game. Ties produce more than one winner.
+func roundWinners(tx *gorm.DB, id int64) ([]string, error) {
+	var (
+		wins []struct {
+			AccountID string
+			Wins      int64
+		}
+		winners []string
+	)
+
+	err := tx.
+		Model(&model.Turn{}).
+		Select("players.account_id, count(*) as wins").
+		Joins("join rounds on rounds.id = turns.round_id").
+		Joins("join players on players.id = turns.player_id").
+		Where("rounds.game_id = ? and turns.is_winner", id).
+		Group("players.account_id").
+		Order("wins desc").
+		Scan(&wins).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range wins {
+		if w.Wins < wins[0].Wins {
+			break
+		}
+		winners = append(winners, w.AccountID)
+	}
+
+	return winners, nil
+}
+
+// setWinners marks the players in accountIds as winners of the game and
+// every other player of the game as not winner
+func setWinners(tx *gorm.DB, id int64, accountIds []string) error {
+	isWinner := gorm.Expr("false")
+	if len(accountIds) > 0 {
+		isWinner = gorm.Expr("player_id in ?", accountIds)
+	}
+
+	return tx.
+		Model(&model.PlayerGame{}).
+		Where(&model.PlayerGame{GameID: id}).
+		Update("is_winner", isWinner).
+		Error
 }
@@ -0,0 +1,125 @@
+package game
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/model"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type RepositorySuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service Repository
+}
+
+func (suite *RepositorySuite) SetupSuite() {
+	dbUrl := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(dbUrl), &gorm.Config{
+		SkipDefaultTransaction: true,
+		TranslateError:         true,
+		Logger:                 logger.Discard,
+	})
+
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.db = db
+
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+	)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.service = *NewRepository(db)
+}
+
+func (suite *RepositorySuite) Cleanup() {
+	suite.T().Helper()
+	if err := suite.db.Exec("TRUNCATE TABLE player_games RESTART IDENTITY CASCADE").Error; err != nil {
+		suite.T().Fatal(err)
+	}
+	if err := suite.db.Exec("TRUNCATE TABLE players RESTART IDENTITY CASCADE").Error; err != nil {
+		suite.T().Fatal(err)
+	}
+	if err := suite.db.Exec("TRUNCATE TABLE games RESTART IDENTITY CASCADE").Error; err != nil {
+		suite.T().Fatal(err)
+	}
+}
+
+func (suite *RepositorySuite) TestCreate() {
+	defer suite.Cleanup()
+
+	g, err := suite.service.Create(&CreateRequest{
+		Name:        "game",
+		Description: "a description",
+		Difficulty:  "hard",
+		Players:     []string{"a", "b"},
+	})
+	suite.Require().NoError(err)
+
+	var stored model.Game
+	suite.Require().NoError(suite.db.First(&stored, g.ID).Error)
+	suite.Equal("game", stored.Name)
+	suite.True(stored.Description.Valid)
+	suite.Equal("a description", stored.Description.String)
+	suite.Equal("hard", stored.Difficulty)
+
+	// an empty description is stored as null
+	g, err = suite.service.Create(&CreateRequest{
+		Name:    "no description",
+		Players: []string{"a"},
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.db.First(&stored, g.ID).Error)
+	suite.False(stored.Description.Valid)
+}
+
+func (suite *RepositorySuite) TestFindByFilterTiebreak() {
+	defer suite.Cleanup()
+
+	for _, name := range []string{"first", "second", "third"} {
+		_, err := suite.service.Create(&CreateRequest{Name: name, Difficulty: "hard"})
+		suite.Require().NoError(err)
+	}
+
+	var (
+		now    = time.Now()
+		filter = Filter{
+			CreatedAt: api.IntervalParams{Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+			Sort:      api.SortParams{Field: "difficulty", Desc: true},
+		}
+		ids []int64
+	)
+	for page := int64(1); page <= 2; page++ {
+		games, n, err := suite.service.FindByFilter(&filter, api.PaginationParams{Page: page, PageSize: 2})
+		suite.Require().NoError(err)
+		suite.Equal(int64(3), n)
+		for _, g := range games {
+			ids = append(ids, g.ID)
+		}
+	}
+
+	suite.Equal([]int64{3, 2, 1}, ids, "ties are sorted by id in the same direction")
+}
+
+func TestServiceSuite(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+	suite.Run(t, new(RepositorySuite))
+}
@@ -5,24 +5,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
+	"runtime/debug"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
 
 const (
-	MaxUploadSize   = 2 * (1 << 20) // 2MB
-	DefaultBodySize = 1 << 18       // 256KB
+	MaxUploadSize   = 2 * (1 << 20)  // 2MB
+	MaxArchiveSize  = 64 * (1 << 20) // 64MB
+	DefaultBodySize = 1 << 18        // 256KB
 
 )
 
-// ApiError represents the http error returned by the REST service.
-// Implements error interface.
+// ApiError represents the http error returned by the REST service. It is
+// written as a Problem. Implements error interface.
 type ApiError struct {
-	code    int
-	Message string `json:"message"`
+	status  int
+	code    Code
+	Message string
+	Errors  FieldErrors
 	err     error
 }
 
@@ -41,13 +45,16 @@ func FromJsonBody[T Validable](r io.ReadCloser) (T, error) {
 	var t T
 
 	if err := json.NewDecoder(r).Decode(&t); err != nil {
-		code := http.StatusBadRequest
+		status := http.StatusBadRequest
+		code := CodeInvalidBody
 		message := "Invalid json body"
 		if err, ok := err.(*http.MaxBytesError); ok {
-			code = http.StatusRequestEntityTooLarge
+			status = http.StatusRequestEntityTooLarge
+			code = CodeBodyTooLarge
 			message = fmt.Sprintf("allowed body size: %s", byteCountIEC(err.Limit))
 		}
 		return t, ApiError{
+			status:  status,
 			code:    code,
 			Message: message,
 			err:     err,
@@ -56,11 +63,19 @@ func FromJsonBody[T Validable](r io.ReadCloser) (T, error) {
 	defer r.Close()
 
 	if err := t.Validate(); err != nil {
-		return t, ApiError{
-			code:    http.StatusBadRequest,
+		apiError := ApiError{
+			status:  http.StatusBadRequest,
+			code:    CodeInvalidParam,
 			Message: err.Error(),
 			err:     err,
 		}
+		// report every violated rule instead of a single message
+		if errs, ok := err.(FieldErrors); ok {
+			apiError.code = CodeValidationFailed
+			apiError.Message = "invalid request body"
+			apiError.Errors = errs
+		}
+		return t, apiError
 	}
 
 	return t, nil
@@ -83,6 +98,21 @@ func FromUrlQuery[T Parseable[T]](r *http.Request, name string, fallback T) (T,
 	return fromString[T](s, name)
 }
 
+// FromUrlOptionalQuery parses the query parameter name, which is nil when
+// missing
+func FromUrlOptionalQuery[T Parseable[T]](r *http.Request, name string) (*T, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return nil, nil
+	}
+
+	v, err := fromString[T](s, name)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
 func fromString[T Parseable[T]](s, name string) (T, error) {
 	var t T
 
@@ -90,7 +120,8 @@ func fromString[T Parseable[T]](s, name string) (T, error) {
 	if err != nil {
 		err = fmt.Errorf("%w %q: %v", ErrInvalidParam, name, err)
 		return t, ApiError{
-			code:    http.StatusBadRequest,
+			status:  http.StatusBadRequest,
+			code:    CodeInvalidParam,
 			err:     err,
 			Message: err.Error(),
 		}
@@ -99,15 +130,70 @@ func fromString[T Parseable[T]](s, name string) (T, error) {
 	return v, nil
 }
 
+// maxBytesBody keeps the body as it was before being limited, so that a
+// route can replace the limit set by an outer middleware instead of nesting
+// a second one below it
+type maxBytesBody struct {
+	io.ReadCloser
+	original io.ReadCloser
+}
+
 func WithMaximumBodySize(n int64) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			r.Body = http.MaxBytesReader(w, r.Body, n)
+			body := r.Body
+			if b, ok := body.(*maxBytesBody); ok {
+				body = b.original
+			}
+			r.Body = &maxBytesBody{
+				ReadCloser: http.MaxBytesReader(w, body, n),
+				original:   body,
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// AllowContentType rejects requests with a body whose media type is not
+// one of contentTypes
+func AllowContentType(contentTypes ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]struct{}, len(contentTypes))
+	for _, ct := range contentTypes {
+		allowed[strings.ToLower(ct)] = struct{}{}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if _, ok := allowed[ct]; !ok {
+				WriteProblem(w, r, fmt.Errorf("%w: %q", ErrUnsupportedMedia, ct))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Recoverer answers with an internal server error when the handler panics
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				// let the server abort the response
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+				WriteProblem(w, r, fmt.Errorf("panic: %v\n%s", rvr, debug.Stack()))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
 func WriteJson(w http.ResponseWriter, statusCode int, v any) error {
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -117,20 +203,7 @@ func WriteJson(w http.ResponseWriter, statusCode int, v any) error {
 func HandlerFunc(f ApiFunction) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			apiError, ok := err.(ApiError)
-
-			if ok {
-				if apiError.code == http.StatusInternalServerError {
-					log.Print(apiError.err)
-				}
-				if err := WriteJson(w, apiError.code, apiError); err != nil {
-					log.Print(err)
-				}
-				return
-			}
-
-			w.WriteHeader(http.StatusInternalServerError)
-			log.Print(err)
+			WriteProblem(w, r, err)
 		}
 	}
 }
@@ -149,38 +222,35 @@ func WithJWTAuthentication(c JWTAuthenticationConfig) func(http.Handler) http.Ha
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeaderParts := strings.Split(r.Header.Get(c.HeaderKey), "Bearer ")
 			if len(authHeaderParts) != 2 {
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				WriteProblem(w, r, ErrUnauthorized)
 				return
 			}
 			token := authHeaderParts[1]
 
 			if token == "" {
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				WriteProblem(w, r, ErrUnauthorized)
 				return
 			}
 
 			var body bytes.Buffer
 			if err := json.NewEncoder(&body).Encode(authRequest{AccessToken: token}); err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				log.Print(err)
+				WriteProblem(w, r, err)
 				return
 			}
 
 			req, err := http.NewRequestWithContext(r.Context(), c.Method, c.AuthEndpoint, &body)
 			if err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				log.Print(err)
+				WriteProblem(w, r, err)
 				return
 			}
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-				log.Print(err)
+				WriteProblem(w, r, err)
 				return
 			}
 			defer resp.Body.Close()
 			if resp.StatusCode != http.StatusOK {
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				WriteProblem(w, r, ErrUnauthorized)
 				return
 			}
 			h.ServeHTTP(w, r)
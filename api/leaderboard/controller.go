@@ -0,0 +1,65 @@
+package leaderboard
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alarmfox/game-repository/api"
+)
+
+type Service interface {
+	Find(difficulty string, i api.IntervalParams, p api.PaginationParams) ([]Entry, int64, error)
+}
+
+type Controller struct {
+	service Service
+}
+
+func NewController(ls Service) *Controller {
+	return &Controller{service: ls}
+}
+
+func (lc *Controller) List(w http.ResponseWriter, r *http.Request) error {
+
+	difficulty, err := api.FromUrlQuery[CustomString](r, "difficulty", "")
+	if err != nil {
+		return err
+	}
+
+	page, err := api.FromUrlQuery[KeyType](r, "page", 1)
+	if err != nil {
+		return err
+	}
+
+	pageSize, err := api.FromUrlQuery[KeyType](r, "pageSize", 10)
+	if err != nil {
+		return err
+	}
+
+	startDate, err := api.FromUrlQuery(r, "startDate", IntervalType(time.Unix(0, 0)))
+	if err != nil {
+		return err
+	}
+
+	endDate, err := api.FromUrlQuery(r, "endDate", IntervalType(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	ip := api.IntervalParams{
+		Start: startDate.AsTime(),
+		End:   endDate.AsTime(),
+	}
+
+	pp := api.PaginationParams{
+		Page:     page.AsInt64(),
+		PageSize: pageSize.AsInt64(),
+	}
+
+	entries, count, err := lc.service.Find(difficulty.AsString(), ip, pp)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, api.MakePaginatedResponse(entries, count, pp))
+}
@@ -0,0 +1,130 @@
+package leaderboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ControllerSuite struct {
+	suite.Suite
+	tServer *httptest.Server
+}
+
+func (suite *ControllerSuite) SetupSuite() {
+	lr := new(MockedRepository)
+	lr.
+		On("Find", mock.Anything, mock.Anything, mock.Anything).
+		Return([]Entry{{AccountID: "a", GamesWon: 1}}, 1, nil)
+
+	controller := NewController(lr)
+
+	r := chi.NewMux()
+	r.Get("/", api.HandlerFunc(controller.List))
+
+	suite.tServer = httptest.NewServer(r)
+}
+
+func (suite *ControllerSuite) TestList() {
+	type input struct {
+		Difficulty string
+		Page       string
+		PageSize   string
+		StartDate  string
+		EndDate    string
+	}
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Input          input
+	}{
+		{
+			Name:           "T05-01-ValidInput",
+			ExpectedStatus: http.StatusOK,
+			Input: input{
+				Difficulty: "easy",
+				Page:       "1",
+				PageSize:   "10",
+				StartDate:  "2023-01-01",
+				EndDate:    "2023-01-31",
+			},
+		},
+		{
+			Name:           "T05-02-DefaultInterval",
+			ExpectedStatus: http.StatusOK,
+			Input: input{
+				Page:     "1",
+				PageSize: "10",
+			},
+		},
+		{
+			Name:           "T05-03-InvalidPage",
+			ExpectedStatus: http.StatusBadRequest,
+			Input: input{
+				Page:     "invalid",
+				PageSize: "10",
+			},
+		},
+		{
+			Name:           "T05-04-InvalidStartDate",
+			ExpectedStatus: http.StatusBadRequest,
+			Input: input{
+				Page:      "1",
+				PageSize:  "10",
+				StartDate: "invalid",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			q := url.Values{}
+			q.Set("page", tc.Input.Page)
+			q.Set("pageSize", tc.Input.PageSize)
+			if tc.Input.Difficulty != "" {
+				q.Set("difficulty", tc.Input.Difficulty)
+			}
+			if tc.Input.StartDate != "" {
+				q.Set("startDate", tc.Input.StartDate)
+			}
+			if tc.Input.EndDate != "" {
+				q.Set("endDate", tc.Input.EndDate)
+			}
+			url := fmt.Sprintf("%s?%s", suite.tServer.URL, q.Encode())
+			res, err := http.Get(url)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func TestControllerSuite(t *testing.T) {
+	suite.Run(t, new(ControllerSuite))
+}
+
+func (suite *ControllerSuite) TearDownSuite() {
+	defer suite.tServer.Close()
+}
+
+type MockedRepository struct {
+	mock.Mock
+}
+
+func (lr *MockedRepository) Find(difficulty string, i api.IntervalParams, p api.PaginationParams) ([]Entry, int64, error) {
+	args := lr.Called(difficulty, i, p)
+	v := args.Get(0)
+	if v == nil {
+		return nil, int64(args.Int(1)), args.Error(2)
+	}
+	return v.([]Entry), int64(args.Int(1)), args.Error(2)
+}
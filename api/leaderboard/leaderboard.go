@@ -0,0 +1,45 @@
+package leaderboard
+
+import (
+	"strconv"
+	"time"
+)
+
+type Entry struct {
+	AccountID   string `json:"accountId"`
+	GamesPlayed int64  `json:"gamesPlayed"`
+	GamesWon    int64  `json:"gamesWon"`
+	TurnsWon    int64  `json:"turnsWon"`
+}
+
+type KeyType int64
+
+func (c KeyType) Parse(s string) (KeyType, error) {
+	a, err := strconv.ParseInt(s, 10, 64)
+	return KeyType(a), err
+}
+
+func (k KeyType) AsInt64() int64 {
+	return int64(k)
+}
+
+type IntervalType time.Time
+
+func (IntervalType) Parse(s string) (IntervalType, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	return IntervalType(t), err
+}
+
+func (k IntervalType) AsTime() time.Time {
+	return time.Time(k)
+}
+
+type CustomString string
+
+func (CustomString) Parse(s string) (CustomString, error) {
+	return CustomString(s), nil
+}
+
+func (s CustomString) AsString() string {
+	return string(s)
+}
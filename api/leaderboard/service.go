@@ -0,0 +1,73 @@
+package leaderboard
+
+import (
+	"github.com/alarmfox/game-repository/api"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (lr *Repository) Find(difficulty string, i api.IntervalParams, p api.PaginationParams) ([]Entry, int64, error) {
+	var (
+		entries []Entry
+		n       int64
+	)
+
+	filter := func(db *gorm.DB) *gorm.DB {
+		db = db.
+			Where("games.deleted_at is null").
+			Scopes(api.WithInterval(i, "games.created_at"))
+		if difficulty != "" {
+			db = db.Where("games.difficulty = ?", difficulty)
+		}
+		return db
+	}
+
+	err := lr.db.Transaction(func(tx *gorm.DB) error {
+		games := tx.
+			Table("player_games").
+			Select("player_games.player_id as account_id, " +
+				"count(*) as games_played, " +
+				"count(*) filter (where player_games.is_winner) as games_won").
+			Joins("join games on games.id = player_games.game_id").
+			Scopes(filter).
+			Group("player_games.player_id")
+
+		turns := tx.
+			Table("turns").
+			Select("players.account_id, count(*) as turns_won").
+			Joins("join players on players.id = turns.player_id").
+			Joins("join rounds on rounds.id = turns.round_id").
+			Joins("join games on games.id = rounds.game_id").
+			Where("turns.is_winner and turns.deleted_at is null").
+			Scopes(filter).
+			Group("players.account_id")
+
+		err := tx.
+			Table("(?) as g", games).
+			Count(&n).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Table("(?) as g", games).
+			Select("g.account_id, g.games_played, g.games_won, coalesce(t.turns_won, 0) as turns_won").
+			Joins("left join (?) as t on t.account_id = g.account_id", turns).
+			Order("g.games_won desc, turns_won desc, g.account_id asc").
+			Scopes(api.WithPagination(p)).
+			Scan(&entries).
+			Error
+	})
+
+	return entries, n, api.MakeServiceError(err)
+}
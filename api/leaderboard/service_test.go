@@ -0,0 +1,241 @@
+package leaderboard
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/model"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type RepositorySuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service Repository
+	now     time.Time
+}
+
+func (suite *RepositorySuite) SetupSuite() {
+	dbUrl := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(dbUrl), &gorm.Config{
+		SkipDefaultTransaction: true,
+		TranslateError:         true,
+		Logger:                 logger.Discard,
+	})
+
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.db = db
+
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+	)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.service = *NewRepository(db)
+}
+
+func (suite *RepositorySuite) Cleanup() {
+	suite.T().Helper()
+	for _, table := range []string{"turns", "rounds", "player_games", "players", "games"} {
+		if err := suite.db.Exec("TRUNCATE TABLE " + table + " RESTART IDENTITY CASCADE").Error; err != nil {
+			suite.T().Fatal(err)
+		}
+	}
+}
+
+// SeedTestData creates players a, b and c (ids 1, 2 and 3) and:
+//   - game 1, hard: won by a against b; a wins its round, b a deleted turn
+//   - game 2, hard: won by b against a; b wins both rounds
+//   - game 3, easy, two days old: won by a against c; a wins its round
+//   - game 4, hard, deleted: won by b against c; b wins its round
+func (suite *RepositorySuite) SeedTestData() {
+	suite.T().Helper()
+
+	suite.now = time.Now()
+	var (
+		old     = suite.now.Add(-48 * time.Hour)
+		deleted = gorm.DeletedAt{Time: suite.now, Valid: true}
+		players = []model.Player{{AccountID: "a"}, {AccountID: "b"}, {AccountID: "c"}}
+		games   = []model.Game{
+			{
+				Name:       "game 1",
+				Difficulty: "hard",
+				Rounds: []model.Round{
+					{Order: 1, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 1, IsWinner: true},
+						{PlayerID: 2},
+					}},
+					{Order: 2, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 2, IsWinner: true, DeletedAt: deleted},
+					}},
+				},
+			},
+			{
+				Name:       "game 2",
+				Difficulty: "hard",
+				Rounds: []model.Round{
+					{Order: 1, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 1},
+						{PlayerID: 2, IsWinner: true},
+					}},
+					{Order: 2, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 2, IsWinner: true},
+					}},
+				},
+			},
+			{
+				Name:       "game 3",
+				Difficulty: "easy",
+				CreatedAt:  old,
+				Rounds: []model.Round{
+					{Order: 1, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 1, IsWinner: true},
+						{PlayerID: 3},
+					}},
+				},
+			},
+			{
+				Name:       "game 4",
+				Difficulty: "hard",
+				DeletedAt:  deleted,
+				Rounds: []model.Round{
+					{Order: 1, TestClassId: "test", DeletedAt: deleted, Turns: []model.Turn{
+						{PlayerID: 2, IsWinner: true, DeletedAt: deleted},
+						{PlayerID: 3, DeletedAt: deleted},
+					}},
+				},
+			},
+		}
+		playerGames = []model.PlayerGame{
+			{PlayerID: "a", GameID: 1, IsWinner: true},
+			{PlayerID: "b", GameID: 1},
+			{PlayerID: "a", GameID: 2},
+			{PlayerID: "b", GameID: 2, IsWinner: true},
+			{PlayerID: "a", GameID: 3, IsWinner: true},
+			{PlayerID: "c", GameID: 3},
+			{PlayerID: "b", GameID: 4, IsWinner: true},
+			{PlayerID: "c", GameID: 4},
+		}
+	)
+
+	err := suite.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&players).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&games).Error; err != nil {
+			return err
+		}
+		return tx.Create(&playerGames).Error
+	})
+
+	if err != nil {
+		suite.T().Fatalf("Failed to seed test data: %v", err)
+	}
+}
+
+func (suite *RepositorySuite) TestFind() {
+	suite.SeedTestData()
+	defer suite.Cleanup()
+
+	var (
+		all    = api.IntervalParams{Start: suite.now.Add(-72 * time.Hour), End: suite.now.Add(time.Hour)}
+		recent = api.IntervalParams{Start: suite.now.Add(-24 * time.Hour), End: suite.now.Add(time.Hour)}
+		page   = api.PaginationParams{Page: 1, PageSize: 10}
+	)
+
+	tcs := []struct {
+		Name       string
+		Difficulty string
+		Interval   api.IntervalParams
+		Pagination api.PaginationParams
+		Expected   []Entry
+		Count      int64
+	}{
+		{
+			Name:       "T05-05-AllDifficulties",
+			Interval:   all,
+			Pagination: page,
+			Expected: []Entry{
+				{AccountID: "a", GamesPlayed: 3, GamesWon: 2, TurnsWon: 2},
+				{AccountID: "b", GamesPlayed: 2, GamesWon: 1, TurnsWon: 2},
+				{AccountID: "c", GamesPlayed: 1, GamesWon: 0, TurnsWon: 0},
+			},
+			Count: 3,
+		},
+		{
+			Name:       "T05-06-TiesBrokenByTurnsWon",
+			Difficulty: "hard",
+			Interval:   all,
+			Pagination: page,
+			Expected: []Entry{
+				{AccountID: "b", GamesPlayed: 2, GamesWon: 1, TurnsWon: 2},
+				{AccountID: "a", GamesPlayed: 2, GamesWon: 1, TurnsWon: 1},
+			},
+			Count: 2,
+		},
+		{
+			Name:       "T05-07-SingleDifficulty",
+			Difficulty: "easy",
+			Interval:   all,
+			Pagination: page,
+			Expected: []Entry{
+				{AccountID: "a", GamesPlayed: 1, GamesWon: 1, TurnsWon: 1},
+				{AccountID: "c", GamesPlayed: 1, GamesWon: 0, TurnsWon: 0},
+			},
+			Count: 2,
+		},
+		{
+			Name:       "T05-08-OutsideInterval",
+			Difficulty: "easy",
+			Interval:   recent,
+			Pagination: page,
+			Expected:   nil,
+			Count:      0,
+		},
+		{
+			Name:       "T05-09-Paginated",
+			Interval:   all,
+			Pagination: api.PaginationParams{Page: 2, PageSize: 1},
+			Expected: []Entry{
+				{AccountID: "b", GamesPlayed: 2, GamesWon: 1, TurnsWon: 2},
+			},
+			Count: 3,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			entries, n, err := suite.service.Find(tc.Difficulty, tc.Interval, tc.Pagination)
+			suite.NoError(err, tc.Name)
+			suite.Equal(tc.Count, n, tc.Name)
+			if len(tc.Expected) == 0 {
+				suite.Empty(entries, tc.Name)
+				return
+			}
+			suite.Equal(tc.Expected, entries, tc.Name)
+		})
+	}
+}
+
+func TestServiceSuite(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+	suite.Run(t, new(RepositorySuite))
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Nullable is a field of a JSON merge patch document (RFC 7396). It records
+// whether the field was present and whether it was explicitly null.
+type Nullable[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		n.Null = true
+		return nil
+	}
+
+	return json.Unmarshal(data, &n.Value)
+}
+
+// Apply adds the field to updates as column when it is present in the patch.
+// A null field sets the column to NULL.
+func (n Nullable[T]) Apply(updates map[string]any, column string) {
+	switch {
+	case !n.Set:
+	case n.Null:
+		updates[column] = nil
+	default:
+		updates[column] = n.Value
+	}
+}
+
+// Present reports whether the patch sets the field to a value
+func (n Nullable[T]) Present() bool {
+	return n.Set && !n.Null
+}
+
+// NotNull fails when the field is null in the patch
+func (n Nullable[T]) NotNull(field string) FieldErrors {
+	if n.Null {
+		return violation(field, "cannot be null")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package player
+
+import (
+	"net/http"
+
+	"github.com/alarmfox/game-repository/api"
+)
+
+type Service interface {
+	FindByAccountId(accountId string) (Profile, error)
+	FindAll(p api.PaginationParams) ([]Player, int64, error)
+	Delete(accountId string) error
+}
+
+type Controller struct {
+	service Service
+}
+
+func NewController(ps Service) *Controller {
+	return &Controller{service: ps}
+}
+
+func (pc *Controller) FindByAccountID(w http.ResponseWriter, r *http.Request) error {
+
+	accountId, err := api.FromUrlParams[AccountIdType](r, "accountId")
+	if err != nil {
+		return err
+	}
+
+	p, err := pc.service.FindByAccountId(accountId.AsString())
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(p.LastModified()), p)
+}
+
+func (pc *Controller) List(w http.ResponseWriter, r *http.Request) error {
+
+	page, err := api.FromUrlQuery[KeyType](r, "page", 1)
+	if err != nil {
+		return err
+	}
+
+	pageSize, err := api.FromUrlQuery[KeyType](r, "pageSize", 10)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := api.FromUrlQuery(r, "cursor", api.Cursor{})
+	if err != nil {
+		return err
+	}
+
+	pp := api.PaginationParams{
+		Page:     page.AsInt64(),
+		PageSize: pageSize.AsInt64(),
+	}
+
+	if cursor != (api.Cursor{}) {
+		pp.Cursor = &cursor
+	}
+
+	players, count, err := pc.service.FindAll(pp)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	res := api.MakeCursorResponse(players, count, pp, cursorOf)
+	api.SetLinkHeader(w, r, res.Metadata)
+	return api.WriteJson(w, http.StatusOK, res)
+}
+
+func (pc *Controller) Delete(w http.ResponseWriter, r *http.Request) error {
+
+	accountId, err := api.FromUrlParams[AccountIdType](r, "accountId")
+	if err != nil {
+		return err
+	}
+
+	if err := pc.service.Delete(accountId.AsString()); err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,198 @@
+package player
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ControllerSuite struct {
+	suite.Suite
+	tServer *httptest.Server
+}
+
+func (suite *ControllerSuite) SetupSuite() {
+	pr := new(MockedRepository)
+	pr.
+		On("FindByAccountId", "a").
+		Return(Profile{Player: Player{ID: 1, AccountID: "a"}}, nil).
+		On("FindByAccountId",
+			mock.MatchedBy(func(id string) bool { return id != "a" })).
+		Return(nil, api.ErrNotFound).
+		On("FindAll", mock.Anything).
+		Return([]Player{}, 0, nil).
+		On("Delete", "a").
+		Return(nil).
+		On("Delete",
+			mock.MatchedBy(func(id string) bool { return id != "a" })).
+		Return(api.ErrNotFound)
+
+	controller := NewController(pr)
+
+	r := chi.NewMux()
+	r.Get("/{accountId}", api.HandlerFunc(controller.FindByAccountID))
+	r.Get("/", api.HandlerFunc(controller.List))
+	r.Delete("/{accountId}", api.HandlerFunc(controller.Delete))
+
+	suite.tServer = httptest.NewServer(r)
+}
+
+func (suite *ControllerSuite) TestFindByAccountID() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Arg            string
+	}{
+		{
+			Name:           "T06-01-PlayerExists",
+			ExpectedStatus: http.StatusOK,
+			Arg:            "a",
+		},
+		{
+			Name:           "T06-02-PlayerNotExists",
+			ExpectedStatus: http.StatusNotFound,
+			Arg:            "b",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Arg))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestFindByAccountIDConditional() {
+	res, err := http.Get(suite.tServer.URL + "/a")
+	suite.Require().NoError(err)
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+	suite.NotEmpty(etag)
+
+	req, err := http.NewRequest(http.MethodGet, suite.tServer.URL+"/a", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("If-None-Match", etag)
+
+	res, err = http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer res.Body.Close()
+	suite.Equal(http.StatusNotModified, res.StatusCode, "T06-08-ProfileNotModified")
+}
+
+func (suite *ControllerSuite) TestList() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Page           string
+		PageSize       string
+	}{
+		{
+			Name:           "T06-03-ValidInput",
+			ExpectedStatus: http.StatusOK,
+			Page:           "1",
+			PageSize:       "10",
+		},
+		{
+			Name:           "T06-04-InvalidPage",
+			ExpectedStatus: http.StatusBadRequest,
+			Page:           "invalid",
+			PageSize:       "10",
+		},
+		{
+			Name:           "T06-05-InvalidPageSize",
+			ExpectedStatus: http.StatusBadRequest,
+			Page:           "1",
+			PageSize:       "invalid",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			q := url.Values{}
+			q.Set("page", tc.Page)
+			q.Set("pageSize", tc.PageSize)
+			res, err := http.Get(fmt.Sprintf("%s?%s", suite.tServer.URL, q.Encode()))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			defer res.Body.Close()
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestDelete() {
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Arg            string
+	}{
+		{
+			Name:           "T06-06-PlayerDeleted",
+			ExpectedStatus: http.StatusNoContent,
+			Arg:            "a",
+		},
+		{
+			Name:           "T06-07-PlayerNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Arg:            "b",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Arg)
+			req, err := http.NewRequest(http.MethodDelete, url, nil)
+			suite.NoError(err)
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
+func TestControllerSuite(t *testing.T) {
+	suite.Run(t, new(ControllerSuite))
+}
+
+func (suite *ControllerSuite) TearDownSuite() {
+	defer suite.tServer.Close()
+}
+
+type MockedRepository struct {
+	mock.Mock
+}
+
+func (pr *MockedRepository) FindByAccountId(accountId string) (Profile, error) {
+	args := pr.Called(accountId)
+	v := args.Get(0)
+	if v == nil {
+		return Profile{}, args.Error(1)
+	}
+	return v.(Profile), args.Error(1)
+}
+
+func (pr *MockedRepository) FindAll(p api.PaginationParams) ([]Player, int64, error) {
+	args := pr.Called(p)
+	v := args.Get(0)
+	if v == nil {
+		return nil, int64(args.Int(1)), args.Error(2)
+	}
+	return v.([]Player), int64(args.Int(1)), args.Error(2)
+}
+
+func (pr *MockedRepository) Delete(accountId string) error {
+	args := pr.Called(accountId)
+	return args.Error(0)
+}
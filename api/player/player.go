@@ -0,0 +1,72 @@
+package player
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/model"
+)
+
+type Player struct {
+	ID        int64     `json:"id"`
+	AccountID string    `json:"accountId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Statistics struct {
+	GamesPlayed  int64      `json:"gamesPlayed"`
+	GamesWon     int64      `json:"gamesWon"`
+	RoundsPlayed int64      `json:"roundsPlayed"`
+	RoundsWon    int64      `json:"roundsWon"`
+	AverageScore *float64   `json:"averageScore"`
+	LastActivity *time.Time `json:"lastActivity"`
+}
+
+type Profile struct {
+	Player
+	Statistics Statistics `json:"statistics"`
+}
+
+// LastModified is the latest update time of the player and of their activity
+func (p Profile) LastModified() time.Time {
+	if p.Statistics.LastActivity != nil && p.Statistics.LastActivity.After(p.UpdatedAt) {
+		return *p.Statistics.LastActivity
+	}
+	return p.UpdatedAt
+}
+
+type KeyType int64
+
+func (c KeyType) Parse(s string) (KeyType, error) {
+	a, err := strconv.ParseInt(s, 10, 64)
+	return KeyType(a), err
+}
+
+func (k KeyType) AsInt64() int64 {
+	return int64(k)
+}
+
+type AccountIdType string
+
+func (AccountIdType) Parse(s string) (AccountIdType, error) {
+	return AccountIdType(s), nil
+}
+
+func (a AccountIdType) AsString() string {
+	return string(a)
+}
+
+func fromModel(p *model.Player) Player {
+	return Player{
+		ID:        p.ID,
+		AccountID: p.AccountID,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+func cursorOf(p Player) api.Cursor {
+	return api.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
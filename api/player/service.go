@@ -0,0 +1,163 @@
+package player
+
+import (
+	"time"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/model"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (pr *Repository) FindByAccountId(accountId string) (Profile, error) {
+	var (
+		player model.Player
+		games  struct {
+			Played       int64
+			Won          int64
+			LastActivity *time.Time
+		}
+		rounds struct {
+			Played       int64
+			Won          int64
+			AverageScore *float64
+			LastActivity *time.Time
+		}
+	)
+
+	err := pr.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where(&model.Player{AccountID: accountId}).
+			First(&player).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Model(&model.PlayerGame{}).
+			Select("count(*) as played, " +
+				"count(*) filter (where player_games.is_winner) as won, " +
+				"max(player_games.updated_at) as last_activity").
+			Joins("join games on games.id = player_games.game_id and games.deleted_at is null").
+			Where(&model.PlayerGame{PlayerID: accountId}).
+			Scan(&games).
+			Error
+		if err != nil {
+			return err
+		}
+
+		// the average score is the mean line coverage of the measured turns
+		return tx.
+			Model(&model.Turn{}).
+			Select("count(*) as played, " +
+				"count(*) filter (where is_winner) as won, " +
+				"avg((scores->>'lineCoverage')::numeric) as average_score, " +
+				"max(updated_at) as last_activity").
+			Where(&model.Turn{PlayerID: player.ID}).
+			Scan(&rounds).
+			Error
+	})
+
+	if err != nil {
+		return Profile{}, api.MakeServiceError(err)
+	}
+
+	lastActivity := games.LastActivity
+	if rounds.LastActivity != nil && (lastActivity == nil || rounds.LastActivity.After(*lastActivity)) {
+		lastActivity = rounds.LastActivity
+	}
+
+	return Profile{
+		Player: fromModel(&player),
+		Statistics: Statistics{
+			GamesPlayed:  games.Played,
+			GamesWon:     games.Won,
+			RoundsPlayed: rounds.Played,
+			RoundsWon:    rounds.Won,
+			AverageScore: rounds.AverageScore,
+			LastActivity: lastActivity,
+		},
+	}, nil
+}
+
+func (pr *Repository) FindAll(p api.PaginationParams) ([]Player, int64, error) {
+	var (
+		players []model.Player
+		n       int64
+	)
+
+	err := pr.db.Transaction(func(tx *gorm.DB) error {
+		if p.Cursor != nil {
+			return tx.
+				Scopes(api.WithCursor(p, "players", true)).
+				Find(&players).
+				Error
+		}
+
+		err := tx.
+			Model(&model.Player{}).
+			Count(&n).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Scopes(api.WithPagination(p)).
+			Order("created_at desc, id desc").
+			Find(&players).
+			Error
+	})
+
+	res := make([]Player, len(players))
+	for i, player := range players {
+		res[i] = fromModel(&player)
+	}
+
+	return res, n, api.MakeServiceError(err)
+}
+
+func (pr *Repository) Delete(accountId string) error {
+	err := pr.db.Transaction(func(tx *gorm.DB) error {
+		var player model.Player
+		err := tx.
+			Where(&model.Player{AccountID: accountId}).
+			First(&player).
+			Error
+		if err != nil {
+			return err
+		}
+
+		// turns cannot outlive their player, not even soft deleted
+		err = tx.
+			Unscoped().
+			Where(&model.Turn{PlayerID: player.ID}).
+			Delete(&model.Turn{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Where(&model.PlayerGame{PlayerID: accountId}).
+			Delete(&model.PlayerGame{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&player).Error
+	})
+
+	return api.MakeServiceError(err)
+}
@@ -0,0 +1,172 @@
+package player
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/model"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type RepositorySuite struct {
+	suite.Suite
+	db      *gorm.DB
+	service Repository
+	now     time.Time
+}
+
+func (suite *RepositorySuite) SetupSuite() {
+	dbUrl := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(dbUrl), &gorm.Config{
+		SkipDefaultTransaction: true,
+		TranslateError:         true,
+		Logger:                 logger.Discard,
+	})
+
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.db = db
+
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+	)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	suite.service = *NewRepository(db)
+}
+
+func (suite *RepositorySuite) Cleanup() {
+	suite.T().Helper()
+	for _, table := range []string{"turns", "rounds", "player_games", "players", "games"} {
+		if err := suite.db.Exec("TRUNCATE TABLE " + table + " RESTART IDENTITY CASCADE").Error; err != nil {
+			suite.T().Fatal(err)
+		}
+	}
+}
+
+// SeedTestData creates player a (id 1), who:
+//   - won game 1, with a winning turn and an unmeasured one
+//   - lost game 2, with a measured turn and a deleted winning one
+//   - won game 3, which is deleted with its winning turn
+//
+// and player b (id 2), who has never played. The deleted rows are the most
+// recent ones, so that they would show in the last activity.
+func (suite *RepositorySuite) SeedTestData() {
+	suite.T().Helper()
+
+	suite.now = time.Now().Truncate(time.Microsecond)
+	var (
+		earlier = suite.now.Add(-time.Hour)
+		later   = suite.now.Add(time.Hour)
+		deleted = gorm.DeletedAt{Time: later, Valid: true}
+		half    = 0.5
+		full    = 1.0
+		none    = 0.0
+		players = []model.Player{{AccountID: "a"}, {AccountID: "b"}}
+		games   = []model.Game{
+			{
+				Name: "game 1",
+				Rounds: []model.Round{
+					{Order: 1, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 1, IsWinner: true, UpdatedAt: earlier, Scores: &model.Scores{LineCoverage: &half}},
+					}},
+					{Order: 2, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 1, UpdatedAt: earlier},
+					}},
+				},
+			},
+			{
+				Name: "game 2",
+				Rounds: []model.Round{
+					{Order: 1, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 1, UpdatedAt: suite.now, Scores: &model.Scores{LineCoverage: &full}},
+					}},
+					{Order: 2, TestClassId: "test", Turns: []model.Turn{
+						{PlayerID: 1, IsWinner: true, UpdatedAt: later, DeletedAt: deleted, Scores: &model.Scores{LineCoverage: &none}},
+					}},
+				},
+			},
+			{
+				Name:      "game 3",
+				DeletedAt: deleted,
+				Rounds: []model.Round{
+					{Order: 1, TestClassId: "test", DeletedAt: deleted, Turns: []model.Turn{
+						{PlayerID: 1, IsWinner: true, UpdatedAt: later, DeletedAt: deleted, Scores: &model.Scores{LineCoverage: &none}},
+					}},
+				},
+			},
+		}
+		playerGames = []model.PlayerGame{
+			{PlayerID: "a", GameID: 1, IsWinner: true, UpdatedAt: earlier},
+			{PlayerID: "a", GameID: 2, UpdatedAt: earlier},
+			{PlayerID: "a", GameID: 3, IsWinner: true, UpdatedAt: later},
+		}
+	)
+
+	err := suite.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&players).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&games).Error; err != nil {
+			return err
+		}
+		return tx.Create(&playerGames).Error
+	})
+
+	if err != nil {
+		suite.T().Fatalf("Failed to seed test data: %v", err)
+	}
+}
+
+func (suite *RepositorySuite) TestFindByAccountId() {
+	suite.SeedTestData()
+	defer suite.Cleanup()
+
+	profile, err := suite.service.FindByAccountId("a")
+	suite.Require().NoError(err)
+	suite.Equal("a", profile.AccountID)
+
+	stats := profile.Statistics
+	suite.Equal(int64(2), stats.GamesPlayed, "deleted games are not counted")
+	suite.Equal(int64(1), stats.GamesWon)
+	suite.Equal(int64(3), stats.RoundsPlayed, "deleted turns are not counted")
+	suite.Equal(int64(1), stats.RoundsWon)
+	suite.Require().NotNil(stats.AverageScore)
+	suite.InDelta(0.75, *stats.AverageScore, 1e-9, "unmeasured turns are not averaged")
+	suite.Require().NotNil(stats.LastActivity)
+	suite.True(suite.now.Equal(*stats.LastActivity),
+		"expected last activity %v; got %v", suite.now, *stats.LastActivity)
+}
+
+func (suite *RepositorySuite) TestFindByAccountIdNeverPlayed() {
+	suite.SeedTestData()
+	defer suite.Cleanup()
+
+	profile, err := suite.service.FindByAccountId("b")
+	suite.Require().NoError(err)
+	suite.Equal(Statistics{}, profile.Statistics)
+
+	_, err = suite.service.FindByAccountId("c")
+	suite.ErrorIs(err, api.ErrNotFound)
+}
+
+func TestServiceSuite(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+	suite.Run(t, new(RepositorySuite))
+}
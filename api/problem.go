@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Code identifies the kind of an error. Codes are stable so clients can
+// branch on them instead of parsing messages.
+type Code string
+
+const (
+	CodeNotFound           Code = "not_found"
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeInvalidParam       Code = "invalid_param"
+	CodeInvalidBody        Code = "invalid_body"
+	CodeValidationFailed   Code = "validation_failed"
+	CodeBodyTooLarge       Code = "body_too_large"
+	CodeUnsupportedMedia   Code = "unsupported_media_type"
+	CodeNotAZip            Code = "not_a_zip"
+	CodeDuplicatedKey      Code = "duplicated_key"
+	CodeInvalidState       Code = "invalid_state"
+	CodePreconditionFailed Code = "precondition_failed"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeTooManyRequests    Code = "too_many_requests"
+	CodeInternal           Code = "internal_error"
+)
+
+// ProblemContentType is the media type of error responses
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of error responses as defined by RFC 7807. Type is
+// derived from Code.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Code      Code        `json:"code"`
+	Instance  string      `json:"instance,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+	Errors    FieldErrors `json:"errors,omitempty"`
+}
+
+func problemType(c Code) string {
+	return "urn:game-repository:problem:" + string(c)
+}
+
+// WriteProblem writes err as a problem document. Errors which are not
+// ApiError are mapped with MakeHttpError.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	apiError, ok := err.(ApiError)
+	if !ok {
+		apiError = makeApiError(err)
+	}
+
+	if apiError.status == http.StatusInternalServerError {
+		log.Print(apiError.err)
+	}
+
+	p := Problem{
+		Type:      problemType(apiError.code),
+		Title:     http.StatusText(apiError.status),
+		Status:    apiError.status,
+		Detail:    apiError.Message,
+		Code:      apiError.code,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    apiError.Errors,
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Print(err)
+	}
+}
+
+// NotFound answers requests to unknown routes
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, ErrNotFound)
+}
+
+// MethodNotAllowed answers requests to known routes with an unsupported
+// method
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, r, ErrMethodNotAllowed)
+}
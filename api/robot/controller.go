@@ -10,6 +10,7 @@ type Service interface {
 	CreateBulk(request *CreateRequest) (int, error)
 	FindByFilter(testClassId string, difficulty string, t RobotType) (Robot, error)
 	DeleteByTestClass(testClassId string) error
+	Patch(id int64, request *PatchRequest) (Robot, error)
 }
 
 type Controller struct {
@@ -67,6 +68,26 @@ func (rc *Controller) FindByFilter(w http.ResponseWriter, r *http.Request) error
 
 }
 
+func (rc *Controller) Patch(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[PatchRequest](r.Body)
+	if err != nil {
+		return err
+	}
+
+	robot, err := rc.service.Patch(id.AsInt64(), &request)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, robot)
+}
+
 func (rc *Controller) Delete(w http.ResponseWriter, r *http.Request) error {
 	testClassId, err := api.FromUrlQuery[CustomString](r, "testClassId", "")
 	if err != nil {
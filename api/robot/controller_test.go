@@ -27,6 +27,13 @@ func (suite *RobotControllerSuite) SetupSuite() {
 		Return(1, nil).
 		On("FindByFilter", mock.Anything, mock.Anything, mock.Anything).
 		Return(Robot{ID: 1}, nil).
+		On("Patch", int64(1),
+			mock.MatchedBy(func(r *PatchRequest) bool { return r.Scores.Null && r.Type.Value == evosuite })).
+		Return(Robot{ID: 1}, nil).
+		On("Patch",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }),
+			mock.Anything).
+		Return(nil, api.ErrNotFound).
 		On("DeleteByTestClass", "a.java").
 		Return(nil).
 		On("DeleteByTestClass",
@@ -40,6 +47,7 @@ func (suite *RobotControllerSuite) SetupSuite() {
 	r.Post("/", api.HandlerFunc(controller.CreateBulk))
 	r.Get("/", api.HandlerFunc(controller.FindByFilter))
 	r.Delete("/", api.HandlerFunc(controller.Delete))
+	r.Patch("/{id}", api.HandlerFunc(controller.Patch))
 
 	suite.tServer = httptest.NewServer(r)
 }
@@ -114,22 +122,37 @@ func (suite *RobotControllerSuite) TestCreate() {
 		{
 			Name:           "T04-04-ValidInput",
 			ExpectedStatus: http.StatusCreated,
-			Body:           `{"robots": [{"testClassId": "a.java", "scores": "some scores", "difficulty": "some difficulty", "type": "randoop"}]}`,
+			Body:           `{"robots": [{"testClassId": "a.java", "scores": {"lineCoverage": 75.2, "compiled": true}, "difficulty": "easy", "type": "randoop"}]}`,
 		},
 		{
 			Name:           "T04-05-InvalidRobotType",
 			ExpectedStatus: http.StatusBadRequest,
-			Body:           `{"robots": [{"testClassId": "a.java", "scores": "some scores", "difficulty": "some difficulty", "type": "ranop"}]}`,
+			Body:           `{"robots": [{"testClassId": "a.java", "scores": {"lineCoverage": 75.2, "compiled": true}, "difficulty": "easy", "type": "ranop"}]}`,
 		},
 		{
 			Name:           "T04-06-MissingField",
 			ExpectedStatus: http.StatusCreated,
-			Body:           `{"robots": [{"testClassId": "a.java", "scores": "some scores", "difficulty": "some difficulty"}]}`,
+			Body:           `{"robots": [{"testClassId": "a.java", "scores": {"lineCoverage": 75.2, "compiled": true}, "difficulty": "easy"}]}`,
 		},
 		{
 			Name:           "T04-07-BadlyFormattedJSON",
 			ExpectedStatus: http.StatusBadRequest,
-			Body:           `{"robots: [{"testClassId": "a.java", "scores": "some scores", "difficulty": "some difficulty", "type": "evosuite}]}`,
+			Body:           `{"robots: [{"testClassId": "a.java", "scores": {"lineCoverage": 75.2, "compiled": true}, "difficulty": "easy", "type": "evosuite}]}`,
+		},
+		{
+			Name:           "T04-15-UnknownDifficulty",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"robots": [{"testClassId": "a.java", "scores": {"lineCoverage": 75.2, "compiled": true}, "difficulty": "impossible", "type": "randoop"}]}`,
+		},
+		{
+			Name:           "T04-16-NoRobots",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"robots": []}`,
+		},
+		{
+			Name:           "T04-17-MutationScoreOutOfRange",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"robots": [{"testClassId": "a.java", "scores": {"mutationScore": -3}, "difficulty": "easy", "type": "randoop"}]}`,
 		},
 	}
 
@@ -184,6 +207,62 @@ func (suite *RobotControllerSuite) TestDelete() {
 
 }
 
+func (suite *RobotControllerSuite) TestPatch() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T04-10-RobotPatched",
+			ExpectedStatus: http.StatusOK,
+			Body:           `{"scores": null, "type": "evosuite"}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T04-11-UnsupportedType",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"type": "junit"}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T04-12-NullDifficulty",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"difficulty": null}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T04-13-RobotNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"scores": {"tests": 10}}`,
+			Id:             `11`,
+		},
+		{
+			Name:           "T04-14-BadId",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"scores": {"tests": 10}}`,
+			Id:             `a`,
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Id)
+			req, err := http.NewRequest(http.MethodPatch,
+				url,
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
 type MockedRobotRepository struct {
 	mock.Mock
 }
@@ -208,3 +287,13 @@ func (m *MockedRobotRepository) DeleteByTestClass(testClassId string) error {
 	args := m.Called(testClassId)
 	return args.Error(0)
 }
+
+func (m *MockedRobotRepository) Patch(id int64, request *PatchRequest) (Robot, error) {
+	args := m.Called(id, request)
+	v := args.Get(0)
+
+	if v == nil {
+		return Robot{}, args.Error(1)
+	}
+	return v.(Robot), args.Error(1)
+}
@@ -3,6 +3,7 @@ package robot
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,13 +12,13 @@ import (
 )
 
 type Robot struct {
-	ID          int64     `json:"id"`
-	TestClassId string    `json:"testClassId"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-	Difficulty  string    `json:"difficulty"`
-	Type        RobotType `json:"type"`
-	Scores      string    `json:"scores"`
+	ID          int64         `json:"id"`
+	TestClassId string        `json:"testClassId"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+	Difficulty  string        `json:"difficulty"`
+	Type        RobotType     `json:"type"`
+	Scores      *model.Scores `json:"scores"`
 }
 type RobotType int8
 
@@ -73,14 +74,26 @@ func (rb RobotType) AsInt8() int8 {
 }
 
 type CreateSingleRequest struct {
-	TestClassId string    `json:"testClassId"`
-	Scores      string    `json:"scores"`
-	Difficulty  string    `json:"difficulty"`
-	Type        RobotType `json:"type"`
+	TestClassId string        `json:"testClassId"`
+	Scores      *model.Scores `json:"scores"`
+	Difficulty  string        `json:"difficulty"`
+	Type        RobotType     `json:"type"`
 }
 
+const maxTestClassIdLength = 255
+
 func (r CreateSingleRequest) Validate() error {
-	return nil
+	return api.Validate(r.rules(""))
+}
+
+// rules validates the robot; fields are reported with prefix
+func (r CreateSingleRequest) rules(prefix string) api.FieldErrors {
+	return api.All(
+		api.Required(prefix+"testClassId", r.TestClassId),
+		api.MaxLength(prefix+"testClassId", r.TestClassId, maxTestClassIdLength),
+		api.Difficulty(prefix+"difficulty", r.Difficulty),
+		api.Optional(prefix+"scores", r.Scores, api.Scores),
+	)
 }
 
 type CreateRequest struct {
@@ -88,16 +101,68 @@ type CreateRequest struct {
 }
 
 func (robots CreateRequest) Validate() error {
-	return nil
+	return api.Validate(
+		api.NotEmpty("robots", robots.Robots),
+		api.Each("robots", robots.Robots, func(field string, r CreateSingleRequest) api.FieldErrors {
+			return r.rules(field + ".")
+		}),
+	)
 }
 
 type UpdateRequest struct {
-	Scores     string `json:"scores"`
-	Difficulty string `json:"difficulty"`
+	Scores     *model.Scores `json:"scores"`
+	Difficulty string        `json:"difficulty"`
+}
+
+func (r UpdateRequest) Validate() error {
+	return api.Validate(
+		api.Optional("scores", r.Scores, api.Scores),
+		api.If(r.Difficulty != "", api.Difficulty("difficulty", r.Difficulty)),
+	)
+}
+
+// PatchRequest is a JSON merge patch (RFC 7396) of a robot. Null scores are
+// cleared; the other fields cannot be null.
+type PatchRequest struct {
+	TestClassId api.Nullable[string]       `json:"testClassId"`
+	Scores      api.Nullable[model.Scores] `json:"scores"`
+	Difficulty  api.Nullable[string]       `json:"difficulty"`
+	Type        api.Nullable[RobotType]    `json:"type"`
+}
+
+func (r PatchRequest) Validate() error {
+	return api.Validate(
+		r.TestClassId.NotNull("testClassId"),
+		api.If(r.TestClassId.Present(),
+			api.Required("testClassId", r.TestClassId.Value),
+			api.MaxLength("testClassId", r.TestClassId.Value, maxTestClassIdLength)),
+		api.If(r.Scores.Present(), api.Scores("scores", r.Scores.Value)),
+		r.Difficulty.NotNull("difficulty"),
+		api.If(r.Difficulty.Present(), api.Difficulty("difficulty", r.Difficulty.Value)),
+		r.Type.NotNull("type"),
+	)
+}
+
+func (r *PatchRequest) updates() map[string]any {
+	updates := make(map[string]any)
+	r.TestClassId.Apply(updates, "test_class_id")
+	r.Scores.Apply(updates, "scores")
+	r.Difficulty.Apply(updates, "difficulty")
+	if r.Type.Set {
+		updates["type"] = r.Type.Value.AsInt8()
+	}
+	return updates
 }
 
-func (UpdateRequest) Validate() error {
-	return nil
+type KeyType int64
+
+func (KeyType) Parse(s string) (KeyType, error) {
+	a, err := strconv.ParseInt(s, 10, 64)
+	return KeyType(a), err
+}
+
+func (k KeyType) AsInt64() int64 {
+	return int64(k)
 }
 
 type CustomString string
@@ -84,6 +84,34 @@ func (gs *RobotStorage) FindByFilter(testClassId string, difficulty string, t Ro
 	return *fromModel(&robot), api.MakeServiceError(err)
 }
 
+func (rs *RobotStorage) Patch(id int64, r *PatchRequest) (Robot, error) {
+	var robot model.Robot
+
+	err := rs.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&robot, id).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if updates := r.updates(); len(updates) > 0 {
+			if err := tx.Model(&robot).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.First(&robot, id).Error
+	})
+
+	if err != nil {
+		return Robot{}, api.MakeServiceError(err)
+	}
+
+	return *fromModel(&robot), nil
+}
+
 func (rs *RobotStorage) DeleteByTestClass(testClassId string) error {
 
 	db := rs.db.Where(&model.Robot{TestClassId: testClassId}).
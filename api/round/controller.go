@@ -10,8 +10,11 @@ type Service interface {
 	Create(request *CreateRequest) (Round, error)
 	FindById(id int64) (Round, error)
 	Delete(id int64) error
-	Update(id int64, request *UpdateRequest) (Round, error)
-	FindByGame(id int64) ([]Round, error)
+	Update(id int64, request *UpdateRequest, p api.Precondition) (Round, error)
+	Patch(id int64, request *PatchRequest, p api.Precondition) (Round, error)
+	FindByFilter(f *Filter, p api.PaginationParams) ([]Round, int64, error)
+	Reorder(gameId int64, request *ReorderRequest) ([]Round, error)
+	CreateBulk(gameId int64, request *BulkCreateRequest) ([]Round, error)
 }
 
 type Controller struct {
@@ -41,6 +44,26 @@ func (rc *Controller) Create(w http.ResponseWriter, r *http.Request) error {
 
 }
 
+func (rc *Controller) CreateBulk(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[BulkCreateRequest](r.Body)
+	if err != nil {
+		return err
+	}
+
+	rounds, err := rc.service.CreateBulk(id.AsInt64(), &request)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusCreated, rounds)
+}
+
 func (rc *Controller) Update(w http.ResponseWriter, r *http.Request) error {
 
 	id, err := api.FromUrlParams[KeyType](r, "id")
@@ -53,12 +76,32 @@ func (rc *Controller) Update(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	round, err := rc.service.Update(id.AsInt64(), &request)
+	round, err := rc.service.Update(id.AsInt64(), &request, api.IfMatch(r))
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, round)
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(round.UpdatedAt), round)
+}
+
+func (rc *Controller) Patch(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[PatchRequest](r.Body)
+	if err != nil {
+		return err
+	}
+
+	round, err := rc.service.Patch(id.AsInt64(), &request, api.IfMatch(r))
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(round.UpdatedAt), round)
 }
 
 func (rc *Controller) FindByID(w http.ResponseWriter, r *http.Request) error {
@@ -74,7 +117,7 @@ func (rc *Controller) FindByID(w http.ResponseWriter, r *http.Request) error {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, round)
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(round.UpdatedAt), round)
 
 }
 
@@ -92,17 +135,61 @@ func (rh *Controller) Delete(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (rc *Controller) List(w http.ResponseWriter, r *http.Request) error {
-	id, err := api.FromUrlQuery(r, "gameId", KeyType(10))
+func (rc *Controller) Reorder(w http.ResponseWriter, r *http.Request) error {
 
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[ReorderRequest](r.Body)
 	if err != nil {
 		return err
 	}
 
-	rounds, err := rc.service.FindByGame(id.AsInt64())
+	rounds, err := rc.service.Reorder(id.AsInt64(), &request)
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 
 	return api.WriteJson(w, http.StatusOK, rounds)
 }
+
+func (rc *Controller) List(w http.ResponseWriter, r *http.Request) error {
+	gameId, err := api.FromUrlQuery[KeyType](r, "gameId", 0)
+	if err != nil {
+		return err
+	}
+
+	testClassId, err := api.FromUrlQuery[CustomString](r, "testClassId", "")
+	if err != nil {
+		return err
+	}
+
+	page, err := api.FromUrlQuery[KeyType](r, "page", 1)
+	if err != nil {
+		return err
+	}
+
+	pageSize, err := api.FromUrlQuery[KeyType](r, "pageSize", 10)
+	if err != nil {
+		return err
+	}
+
+	f := Filter{
+		GameID:      gameId.AsInt64(),
+		TestClassId: testClassId.AsString(),
+	}
+
+	pp := api.PaginationParams{
+		Page:     page.AsInt64(),
+		PageSize: pageSize.AsInt64(),
+	}
+
+	rounds, count, err := rc.service.FindByFilter(&f, pp)
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, api.MakePaginatedResponse(rounds, count, pp))
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
 	"testing"
 
 	"github.com/alarmfox/game-repository/api"
@@ -38,15 +37,33 @@ func (suite *ControllerSuite) SetupSuite() {
 			mock.MatchedBy(func(id int64) bool { return id != 1 })).
 		Return(api.ErrNotFound).
 		On("Update", int64(1),
-			&UpdateRequest{}).
+			&UpdateRequest{}, api.Precondition("")).
 		Return(Round{}, nil).
+		On("Patch", int64(1),
+			mock.MatchedBy(func(r *PatchRequest) bool { return r.ClosedAt.Null }),
+			api.Precondition("")).
+		Return(Round{ID: 1}, nil).
+		On("Patch",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }),
+			mock.Anything, mock.Anything).
+		Return(nil, api.ErrNotFound).
 		On("Update",
 			mock.MatchedBy(func(id int64) bool { return id != 1 }),
-			&UpdateRequest{}).
+			&UpdateRequest{}, mock.Anything).
 		Return(nil, api.ErrNotFound).
-		On("FindByGame", int64(1)).
-		Return([]Round{}, nil).
-		On("FindByGame", mock.MatchedBy(func(id int64) bool { return id != 1 })).
+		On("FindByFilter", mock.MatchedBy(func(f *Filter) bool { return f.GameID <= 1 }), mock.Anything).
+		Return([]Round{}, int64(0), nil).
+		On("FindByFilter", mock.MatchedBy(func(f *Filter) bool { return f.GameID > 1 }), mock.Anything).
+		Return(nil, int64(0), api.ErrNotFound).
+		On("Reorder", int64(1), &ReorderRequest{Rounds: []int64{2, 1}}).
+		Return([]Round{{ID: 2, Order: 1}, {ID: 1, Order: 2}}, nil).
+		On("Reorder", int64(1), &ReorderRequest{Rounds: []int64{2}}).
+		Return(nil, api.ErrInvalidParam).
+		On("Reorder", mock.MatchedBy(func(id int64) bool { return id != 1 }), mock.Anything).
+		Return(nil, api.ErrNotFound).
+		On("CreateBulk", int64(1), mock.Anything).
+		Return([]Round{{ID: 1, Order: 1}, {ID: 2, Order: 2}}, nil).
+		On("CreateBulk", mock.MatchedBy(func(id int64) bool { return id != 1 }), mock.Anything).
 		Return(nil, api.ErrNotFound)
 
 	controller := NewController(rr)
@@ -57,6 +74,9 @@ func (suite *ControllerSuite) SetupSuite() {
 	r.Post("/", api.HandlerFunc(controller.Create))
 	r.Delete("/{id}", api.HandlerFunc(controller.Delete))
 	r.Put("/{id}", api.HandlerFunc(controller.Update))
+	r.Patch("/{id}", api.HandlerFunc(controller.Patch))
+	r.Post("/games/{id}/rounds/reorder", api.HandlerFunc(controller.Reorder))
+	r.Post("/games/{id}/rounds:bulk", api.HandlerFunc(controller.CreateBulk))
 
 	suite.tServer = httptest.NewServer(r)
 }
@@ -116,7 +136,22 @@ func (suite *ControllerSuite) TestCreate() {
 		{
 			Name:           "T01-06-GameNotExists",
 			ExpectedStatus: http.StatusNotFound,
-			Body:           `{"gameId": 2}`,
+			Body:           `{"gameId": 2, "testClassId": "a.java"}`,
+		},
+		{
+			Name:           "T01-20-MissingTestClass",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"gameId": 1}`,
+		},
+		{
+			Name:           "T01-21-ClosedBeforeStart",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"gameId": 1, "testClassId": "a.java", "startedAt": "2023-06-02T10:00:00Z", "closedAt": "2023-06-01T10:00:00Z"}`,
+		},
+		{
+			Name:           "T01-26-NegativeTimeLimit",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"gameId": 1, "testClassId": "a.java", "timeLimit": -60}`,
 		},
 	}
 	for _, tc := range tcs {
@@ -223,6 +258,50 @@ func (suite *ControllerSuite) TestUpdate() {
 	}
 
 }
+func (suite *ControllerSuite) TestPatch() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T01-17-RoundPatched",
+			ExpectedStatus: http.StatusOK,
+			Body:           `{"closedAt": null}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-18-NullTestClass",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"testClassId": null}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-19-RoundNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"closedAt": null}`,
+			Id:             `11`,
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Id)
+			req, err := http.NewRequest(http.MethodPatch,
+				url,
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
 func (suite *ControllerSuite) TestList() {
 
 	tcs := []struct {
@@ -233,26 +312,39 @@ func (suite *ControllerSuite) TestList() {
 		{
 			Name:           "T01-14-OkList",
 			ExpectedStatus: http.StatusOK,
-			Input:          "1",
+			Input:          "gameId=1",
 		},
 		{
 			Name:           "T01-15-InvalidId",
 			ExpectedStatus: http.StatusBadRequest,
-			Input:          "invalid",
+			Input:          "gameId=invalid",
 		},
 		{
 			Name:           "T01-16-RoundNotFound",
 			ExpectedStatus: http.StatusNotFound,
-			Input:          "2",
+			Input:          "gameId=2",
+		},
+		{
+			Name:           "T01-31-AllGames",
+			ExpectedStatus: http.StatusOK,
+			Input:          "",
+		},
+		{
+			Name:           "T01-32-ByTestClass",
+			ExpectedStatus: http.StatusOK,
+			Input:          "testClassId=Calculator&page=2&pageSize=5",
+		},
+		{
+			Name:           "T01-33-InvalidPage",
+			ExpectedStatus: http.StatusBadRequest,
+			Input:          "page=first",
 		},
 	}
 
 	for _, tc := range tcs {
 		tc := tc
 		suite.T().Run(tc.Name, func(t *testing.T) {
-			q := url.Values{}
-			q.Add("gameId", tc.Input)
-			url := fmt.Sprintf("%s?%s", suite.tServer.URL, q.Encode())
+			url := fmt.Sprintf("%s?%s", suite.tServer.URL, tc.Input)
 			res, err := http.Get(url)
 			suite.NoError(err)
 			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
@@ -263,6 +355,98 @@ func (suite *ControllerSuite) TestList() {
 
 }
 
+func (suite *ControllerSuite) TestReorder() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T01-22-RoundsReordered",
+			ExpectedStatus: http.StatusOK,
+			Body:           `{"rounds": [2, 1]}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-23-MissingRounds",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"rounds": [2]}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-24-DuplicatedRounds",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"rounds": [2, 2]}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-25-GameNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"rounds": [2, 1]}`,
+			Id:             `11`,
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/games/%s/rounds/reorder", suite.tServer.URL, tc.Id)
+			res, err := http.Post(url,
+				"application/json",
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestCreateBulk() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T01-27-RoundsCreated",
+			ExpectedStatus: http.StatusCreated,
+			Body:           `{"rounds": [{"testClassId": "a.java"}, {"testClassId": "b.java", "timeLimit": 600}]}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-28-NoRounds",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"rounds": []}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-29-InvalidTestClass",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"rounds": [{"testClassId": "a.java"}, {"testClassId": ""}]}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T01-30-GameNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"rounds": [{"testClassId": "a.java"}]}`,
+			Id:             `11`,
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/games/%s/rounds:bulk", suite.tServer.URL, tc.Id)
+			res, err := http.Post(url,
+				"application/json",
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
 func TestControllerSuite(t *testing.T) {
 	suite.Run(t, new(ControllerSuite))
 }
@@ -301,8 +485,8 @@ func (gr *MockedRepository) Delete(id int64) error {
 	return args.Error(0)
 }
 
-func (gr *MockedRepository) Update(id int64, ur *UpdateRequest) (Round, error) {
-	args := gr.Called(id, ur)
+func (gr *MockedRepository) Update(id int64, ur *UpdateRequest, p api.Precondition) (Round, error) {
+	args := gr.Called(id, ur, p)
 	v := args.Get(0)
 
 	if v == nil {
@@ -311,13 +495,43 @@ func (gr *MockedRepository) Update(id int64, ur *UpdateRequest) (Round, error) {
 	return v.(Round), args.Error(1)
 }
 
-func (gr *MockedRepository) FindByGame(id int64) ([]Round, error) {
-	args := gr.Called(id)
+func (gr *MockedRepository) FindByFilter(f *Filter, p api.PaginationParams) ([]Round, int64, error) {
+	args := gr.Called(f, p)
+	v := args.Get(0)
+
+	if v == nil {
+		return nil, args.Get(1).(int64), args.Error(2)
+	}
+	return v.([]Round), args.Get(1).(int64), args.Error(2)
+
+}
+
+func (gr *MockedRepository) Patch(id int64, request *PatchRequest, p api.Precondition) (Round, error) {
+	args := gr.Called(id, request, p)
+	v := args.Get(0)
+
+	if v == nil {
+		return Round{}, args.Error(1)
+	}
+	return v.(Round), args.Error(1)
+}
+
+func (gr *MockedRepository) Reorder(gameId int64, request *ReorderRequest) ([]Round, error) {
+	args := gr.Called(gameId, request)
 	v := args.Get(0)
 
 	if v == nil {
 		return nil, args.Error(1)
 	}
 	return v.([]Round), args.Error(1)
+}
+
+func (gr *MockedRepository) CreateBulk(gameId int64, request *BulkCreateRequest) ([]Round, error) {
+	args := gr.Called(gameId, request)
+	v := args.Get(0)
 
+	if v == nil {
+		return nil, args.Error(1)
+	}
+	return v.([]Round), args.Error(1)
 }
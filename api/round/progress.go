@@ -0,0 +1,113 @@
+package round
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alarmfox/game-repository/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockGame locks, until the end of the transaction tx, the game of the round
+// and returns its identifier. Games are locked before their rounds and turns.
+func LockGame(tx *gorm.DB, id int64) (int64, error) {
+	var round model.Round
+	if err := tx.Select("game_id").First(&round, id).Error; err != nil {
+		return 0, err
+	}
+
+	err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&model.Game{}, round.GameID).
+		Error
+
+	return round.GameID, err
+}
+
+// Advance moves the current round of the game to the first open round which
+// does not precede it. The game is closed once every round is closed.
+func Advance(tx *gorm.DB, gameId int64) error {
+	var game model.Game
+	err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&game, gameId).
+		Error
+	if err != nil {
+		return err
+	}
+
+	if game.Status == model.GameStatusClosed {
+		return nil
+	}
+
+	var next model.Round
+	err = tx.
+		Where(&model.Round{GameID: gameId}).
+		Where("closed_at is null and \"order\" >= ?", game.CurrentRound).
+		Order("\"order\" asc").
+		Take(&next).
+		Error
+	if err == nil {
+		if next.Order == game.CurrentRound {
+			return nil
+		}
+		return tx.
+			Model(&game).
+			Update("current_round", next.Order).
+			Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var open int64
+	err = tx.
+		Model(&model.Round{}).
+		Where(&model.Round{GameID: gameId}).
+		Where("closed_at is null").
+		Count(&open).
+		Error
+	if err != nil || open > 0 {
+		return err
+	}
+
+	return tx.
+		Model(&game).
+		Updates(map[string]any{
+			"status":    model.GameStatusClosed,
+			"closed_at": time.Now(),
+		}).
+		Error
+}
+
+// CloseIfPlayed closes the round when every turn of the round is closed and
+// advances its game
+func CloseIfPlayed(tx *gorm.DB, id int64) error {
+	var round model.Round
+	if err := tx.First(&round, id).Error; err != nil {
+		return err
+	}
+
+	if round.ClosedAt != nil {
+		return nil
+	}
+
+	var open int64
+	err := tx.
+		Model(&model.Turn{}).
+		Where(&model.Turn{RoundID: id}).
+		Where("closed_at is null").
+		Count(&open).
+		Error
+	if err != nil || open > 0 {
+		return err
+	}
+
+	if err := tx.Model(&round).Update("closed_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	return Advance(tx, round.GameID)
+}
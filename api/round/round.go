@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/alarmfox/game-repository/api"
 	"github.com/alarmfox/game-repository/model"
 )
 
@@ -16,6 +17,7 @@ type Round struct {
 	UpdatedAt   time.Time  `json:"updatedAt"`
 	StartedAt   *time.Time `json:"startedAt"`
 	ClosedAt    *time.Time `json:"closedAt"`
+	TimeLimit   *int64     `json:"timeLimit"`
 }
 
 type CreateRequest struct {
@@ -23,19 +25,117 @@ type CreateRequest struct {
 	TestClassId string     `json:"testClassId"`
 	StartedAt   *time.Time `json:"startedAt,omitempty"`
 	ClosedAt    *time.Time `json:"closedAt,omitempty"`
+	TimeLimit   *int64     `json:"timeLimit,omitempty"`
 }
 
-func (CreateRequest) Validate() error {
-	return nil
+const maxTestClassIdLength = 255
+
+func (r CreateRequest) Validate() error {
+	return api.Validate(
+		api.Positive("gameId", r.GameId),
+		api.Required("testClassId", r.TestClassId),
+		api.MaxLength("testClassId", r.TestClassId, maxTestClassIdLength),
+		api.Before("startedAt", r.StartedAt, "closedAt", r.ClosedAt),
+		api.Optional("timeLimit", r.TimeLimit, api.Positive[int64]),
+	)
 }
 
 type UpdateRequest struct {
 	StartedAt *time.Time `json:"startedAt,omitempty"`
 	ClosedAt  *time.Time `json:"closedAt,omitempty"`
+	TimeLimit *int64     `json:"timeLimit,omitempty"`
+}
+
+func (r UpdateRequest) Validate() error {
+	return api.Validate(
+		api.Before("startedAt", r.StartedAt, "closedAt", r.ClosedAt),
+		api.Optional("timeLimit", r.TimeLimit, api.Positive[int64]),
+	)
+}
+
+// PatchRequest is a JSON merge patch (RFC 7396) of a round. Null dates and
+// time limit are cleared; the test class cannot be null.
+type PatchRequest struct {
+	TestClassId api.Nullable[string]    `json:"testClassId"`
+	StartedAt   api.Nullable[time.Time] `json:"startedAt"`
+	ClosedAt    api.Nullable[time.Time] `json:"closedAt"`
+	TimeLimit   api.Nullable[int64]     `json:"timeLimit"`
+}
+
+func (r PatchRequest) Validate() error {
+	return api.Validate(
+		r.TestClassId.NotNull("testClassId"),
+		api.If(r.TestClassId.Present(),
+			api.Required("testClassId", r.TestClassId.Value),
+			api.MaxLength("testClassId", r.TestClassId.Value, maxTestClassIdLength)),
+		api.If(r.StartedAt.Present() && r.ClosedAt.Present(),
+			api.Before("startedAt", &r.StartedAt.Value, "closedAt", &r.ClosedAt.Value)),
+		api.If(r.TimeLimit.Present(), api.Positive("timeLimit", r.TimeLimit.Value)),
+	)
+}
+
+func (r *PatchRequest) updates() map[string]any {
+	updates := make(map[string]any)
+	r.TestClassId.Apply(updates, "test_class_id")
+	r.StartedAt.Apply(updates, "started_at")
+	r.ClosedAt.Apply(updates, "closed_at")
+	r.TimeLimit.Apply(updates, "time_limit")
+	return updates
+}
+
+// BulkCreateRequest lists, in order, the rounds to append to a game
+type BulkCreateRequest struct {
+	Rounds []BulkRound `json:"rounds"`
+}
+
+type BulkRound struct {
+	TestClassId string     `json:"testClassId"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	ClosedAt    *time.Time `json:"closedAt,omitempty"`
+	TimeLimit   *int64     `json:"timeLimit,omitempty"`
+}
+
+func (r BulkCreateRequest) Validate() error {
+	return api.Validate(
+		api.NotEmpty("rounds", r.Rounds),
+		api.Each("rounds", r.Rounds, func(field string, round BulkRound) api.FieldErrors {
+			return api.All(
+				api.Required(field+".testClassId", round.TestClassId),
+				api.MaxLength(field+".testClassId", round.TestClassId, maxTestClassIdLength),
+				api.Before(field+".startedAt", round.StartedAt, field+".closedAt", round.ClosedAt),
+				api.Optional(field+".timeLimit", round.TimeLimit, api.Positive[int64]),
+			)
+		}),
+	)
+}
+
+// ReorderRequest lists the rounds of a game in their new order
+type ReorderRequest struct {
+	Rounds []int64 `json:"rounds"`
+}
+
+func (r ReorderRequest) Validate() error {
+	return api.Validate(
+		api.NotEmpty("rounds", r.Rounds),
+		api.Each("rounds", r.Rounds, api.Positive[int64]),
+		api.Unique("rounds", r.Rounds),
+	)
+}
+
+// Filter selects the rounds to list. Zero values match every round.
+type Filter struct {
+	GameID      int64
+	TestClassId string
+}
+
+type CustomString string
+
+func (CustomString) Parse(s string) (CustomString, error) {
+	return CustomString(s), nil
 }
 
-func (UpdateRequest) Validate() error {
-	return nil
+func (s CustomString) AsString() string {
+	return string(s)
 }
 
 type KeyType int64
@@ -58,6 +158,7 @@ func fromModel(r *model.Round) Round {
 		TestClassId: r.TestClassId,
 		StartedAt:   r.StartedAt,
 		ClosedAt:    r.ClosedAt,
+		TimeLimit:   r.TimeLimit,
 		GameID:      r.GameID,
 	}
 }
@@ -1,7 +1,8 @@
 package round
 
 import (
-	"errors"
+	"fmt"
+	"time"
 
 	"github.com/alarmfox/game-repository/api"
 	"github.com/alarmfox/game-repository/model"
@@ -24,13 +25,29 @@ func (rs *Repository) Create(r *CreateRequest) (Round, error) {
 
 	err := rs.db.Transaction(func(tx *gorm.DB) error {
 
-		var lastRound model.Round
-		err := tx.Where(&model.Round{GameID: r.GameId}).
-			Order("\"order\" desc").
-			Last(&lastRound).
+		// rounds of a game are created one at a time so that orders
+		// stay consecutive
+		var game model.Game
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&game, r.GameId).
 			Error
+		if err != nil {
+			return err
+		}
 
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		if game.Status == model.GameStatusClosed {
+			return fmt.Errorf("%w: game is closed", api.ErrInvalidState)
+		}
+
+		var last int
+		err = tx.
+			Model(&model.Round{}).
+			Where(&model.Round{GameID: r.GameId}).
+			Select("coalesce(max(\"order\"), 0)").
+			Scan(&last).
+			Error
+		if err != nil {
 			return err
 		}
 
@@ -39,7 +56,8 @@ func (rs *Repository) Create(r *CreateRequest) (Round, error) {
 			TestClassId: r.TestClassId,
 			StartedAt:   r.StartedAt,
 			ClosedAt:    r.ClosedAt,
-			Order:       lastRound.Order + 1,
+			TimeLimit:   r.TimeLimit,
+			Order:       last + 1,
 		}
 
 		return tx.Create(&round).Error
@@ -49,19 +67,115 @@ func (rs *Repository) Create(r *CreateRequest) (Round, error) {
 	return fromModel(&round), api.MakeServiceError(err)
 }
 
-func (rs *Repository) Update(id int64, r *UpdateRequest) (Round, error) {
+// CreateBulk appends the rounds to the game with consecutive orders. Either
+// every round is created or none is.
+func (rs *Repository) CreateBulk(gameId int64, r *BulkCreateRequest) ([]Round, error) {
+	rounds := make([]model.Round, len(r.Rounds))
 
-	var (
-		round model.Round = model.Round{ID: id}
-		err   error
-	)
+	err := rs.db.Transaction(func(tx *gorm.DB) error {
+		var game model.Game
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&game, gameId).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if game.Status == model.GameStatusClosed {
+			return fmt.Errorf("%w: game is closed", api.ErrInvalidState)
+		}
+
+		var last int
+		err = tx.
+			Model(&model.Round{}).
+			Where(&model.Round{GameID: gameId}).
+			Select("coalesce(max(\"order\"), 0)").
+			Scan(&last).
+			Error
+		if err != nil {
+			return err
+		}
+
+		for i, round := range r.Rounds {
+			rounds[i] = model.Round{
+				GameID:      gameId,
+				TestClassId: round.TestClassId,
+				StartedAt:   round.StartedAt,
+				ClosedAt:    round.ClosedAt,
+				TimeLimit:   round.TimeLimit,
+				Order:       last + i + 1,
+			}
+		}
+
+		return tx.Create(&rounds).Error
+	})
+
+	if err != nil {
+		return nil, api.MakeServiceError(err)
+	}
+
+	resp := make([]Round, len(rounds))
+	for i, round := range rounds {
+		resp[i] = fromModel(&round)
+	}
+
+	return resp, nil
+}
+
+func (rs *Repository) Update(id int64, r *UpdateRequest, p api.Precondition) (Round, error) {
+	return rs.update(id, r, p)
+}
+
+func (rs *Repository) Patch(id int64, r *PatchRequest, p api.Precondition) (Round, error) {
+	return rs.update(id, r.updates(), p)
+}
+
+// update applies updates, a struct or a column map, to the round when p is
+// satisfied and returns the round as stored. Closing the round advances its
+// game.
+func (rs *Repository) update(id int64, updates any, p api.Precondition) (Round, error) {
+	var round model.Round
+
+	err := rs.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := LockGame(tx, id); err != nil {
+			return err
+		}
+
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&round, id).
+			Error
+		if err != nil {
+			return err
+		}
+		open := round.ClosedAt == nil
+
+		if !p.Matches(api.ETag(round.UpdatedAt)) {
+			return api.ErrPreconditionFailed
+		}
+
+		if m, ok := updates.(map[string]any); !ok || len(m) > 0 {
+			if err := tx.Model(&round).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.First(&round, id).Error; err != nil {
+			return err
+		}
+
+		if open && round.ClosedAt != nil {
+			return Advance(tx, round.GameID)
+		}
+		return nil
+	})
 
-	err = rs.db.Model(&round).Updates(r).Error
 	if err != nil {
 		return Round{}, api.MakeServiceError(err)
 	}
 
-	return fromModel(&round), api.MakeServiceError(err)
+	return fromModel(&round), nil
 }
 
 func (rs *Repository) FindById(id int64) (Round, error) {
@@ -74,46 +188,216 @@ func (rs *Repository) FindById(id int64) (Round, error) {
 	return fromModel(&round), api.MakeServiceError(err)
 }
 
-func (rs *Repository) FindByGame(id int64) ([]Round, error) {
-	var rounds []model.Round
+// FindByFilter lists a page of the rounds matching f, ordered by game and
+// order, along with the number of matching rounds
+func (rs *Repository) FindByFilter(f *Filter, p api.PaginationParams) ([]Round, int64, error) {
+	var (
+		rounds []model.Round
+		n      int64
+	)
 
-	err := rs.db.
-		Where(&model.Round{GameID: id}).
-		Order("\"order\" asc").
-		Find(&rounds).
-		Error
+	err := rs.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.
+			Model(&model.Round{}).
+			Scopes(withFilter(f))
+
+		if err := query.Count(&n).Error; err != nil {
+			return err
+		}
+
+		return query.
+			Scopes(api.WithPagination(p)).
+			Order("game_id asc, \"order\" asc").
+			Find(&rounds).
+			Error
+	})
 
 	resp := make([]Round, len(rounds))
 	for i, round := range rounds {
 		resp[i] = fromModel(&round)
 	}
 
-	return resp, api.MakeServiceError(err)
+	return resp, n, api.MakeServiceError(err)
+}
+
+func withFilter(f *Filter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f.GameID != 0 {
+			db = db.Where("game_id = ?", f.GameID)
+		}
+		return db.Scopes(api.WithEqual("test_class_id", f.TestClassId))
+	}
 }
 
 func (rs *Repository) Delete(id int64) error {
 	err := rs.db.Transaction(func(tx *gorm.DB) error {
+		gameId, err := LockGame(tx, id)
+		if err != nil {
+			return err
+		}
+
+		var game model.Game
+		if err := tx.First(&game, gameId).Error; err != nil {
+			return err
+		}
+
 		var round model.Round
-		db := rs.db.
-			Where(&model.Round{ID: id}).
-			Clauses(clause.Returning{}).
-			Delete(&round)
+		err = tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&round, id).
+			Error
+		if err != nil {
+			return err
+		}
 
-		if db.Error != nil {
-			return db.Error
-		} else if db.RowsAffected < 1 {
-			return api.ErrNotFound
+		now := time.Now()
+		err = tx.
+			Model(&model.Turn{}).
+			Where(&model.Turn{RoundID: id}).
+			Update("deleted_at", now).
+			Error
+		if err != nil {
+			return err
 		}
 
-		err := rs.db.
-			Model(&model.Round{}).
-			Where(&model.Round{GameID: round.GameID}).
-			Where("\"order\" > ?", round.Order).
-			UpdateColumn("order", gorm.Expr("\"order\" - ?", 1)).
+		err = tx.
+			Model(&round).
+			Update("deleted_at", now).
 			Error
+		if err != nil {
+			return err
+		}
 
-		return err
+		if err := shiftOrders(tx, round.GameID, round.Order, -1); err != nil {
+			return err
+		}
+
+		// the game is touched even if its current round is left, because the
+		// orders of its rounds changed
+		currentRound := game.CurrentRound
+		if round.Order < game.CurrentRound {
+			currentRound--
+		}
+
+		return tx.
+			Model(&game).
+			Updates(map[string]any{"current_round": currentRound, "updated_at": now}).
+			Error
 	})
 
 	return api.MakeServiceError(err)
 }
+
+// Reorder assigns to the rounds of the game the position they have in the
+// request. The current round of the game follows its round.
+func (rs *Repository) Reorder(gameId int64, r *ReorderRequest) ([]Round, error) {
+	var rounds []model.Round
+
+	err := rs.db.Transaction(func(tx *gorm.DB) error {
+		var game model.Game
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&game, gameId).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(&model.Round{GameID: gameId}).
+			Find(&rounds).
+			Error
+		if err != nil {
+			return err
+		}
+
+		orders := make(map[int64]int, len(rounds))
+		for _, round := range rounds {
+			orders[round.ID] = round.Order
+		}
+
+		if len(r.Rounds) != len(rounds) {
+			return fmt.Errorf("%w: rounds must list the %d rounds of the game", api.ErrInvalidParam, len(rounds))
+		}
+
+		current := -1
+		for i, id := range r.Rounds {
+			order, ok := orders[id]
+			if !ok {
+				return fmt.Errorf("%w: round %d does not belong to game %d", api.ErrInvalidParam, id, gameId)
+			}
+			if order == game.CurrentRound {
+				current = i + 1
+			}
+		}
+
+		// move every round out of the way of the unique index first
+		if err := shiftOrders(tx, gameId, 0, 0); err != nil {
+			return err
+		}
+
+		for i, id := range r.Rounds {
+			err := tx.
+				Model(&model.Round{}).
+				Where("id = ?", id).
+				Update("order", i+1).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if current == -1 {
+			current = game.CurrentRound
+		}
+
+		err = tx.
+			Model(&game).
+			Updates(map[string]any{"current_round": current, "updated_at": time.Now()}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Where(&model.Round{GameID: gameId}).
+			Order("\"order\" asc").
+			Find(&rounds).
+			Error
+	})
+
+	if err != nil {
+		return nil, api.MakeServiceError(err)
+	}
+
+	resp := make([]Round, len(rounds))
+	for i, round := range rounds {
+		resp[i] = fromModel(&round)
+	}
+
+	return resp, nil
+}
+
+// shiftOrders adds delta to the order of the rounds of the game placed after
+// order. Orders are negated first so that the unique index on game and order
+// holds after each row is updated. With a zero delta rounds are left with
+// negative orders.
+func shiftOrders(tx *gorm.DB, gameId int64, order, delta int) error {
+	err := tx.
+		Model(&model.Round{}).
+		Where(&model.Round{GameID: gameId}).
+		Where("\"order\" > ?", order).
+		UpdateColumn("order", gorm.Expr("-\"order\"")).
+		Error
+	if err != nil || delta == 0 {
+		return err
+	}
+
+	return tx.
+		Model(&model.Round{}).
+		Where(&model.Round{GameID: gameId}).
+		Where("\"order\" < 0").
+		Update("order", gorm.Expr("? - \"order\"", delta)).
+		Error
+}
@@ -0,0 +1,120 @@
+package turn
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+
+	"github.com/alarmfox/game-repository/model"
+	"github.com/alarmfox/game-repository/storage"
+	"gorm.io/gorm"
+)
+
+// Blob is a file being added to the content addressed store of turn files.
+// Files with the same content are stored once, named after their SHA-256:
+// every metadata with the path of a blob is a reference to it.
+type Blob struct {
+	content  []byte
+	Size     int64
+	Checksum string
+}
+
+// NewBlob reads r, computing its checksum on the way. Callers limit r to the
+// maximum upload size.
+func NewBlob(r io.Reader) (*Blob, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	return &Blob{
+		content:  content,
+		Size:     int64(len(content)),
+		Checksum: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// OpenZip reads the blob as a zip archive
+func (b *Blob) OpenZip() (*zip.Reader, error) {
+	return zip.NewReader(bytes.NewReader(b.content), b.Size)
+}
+
+// openZip reads a stored turn file as a zip archive. Backends returning files
+// that cannot be read at an offset are read in memory.
+func openZip(f io.ReadSeeker) (*zip.Reader, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		ra = bytes.NewReader(content)
+	}
+
+	return zip.NewReader(ra, size)
+}
+
+// Store puts the blob in s, unless a blob with the same content is there
+// already, and returns metadata referencing it. The blob is locked until tx
+// ends, so that cleanup cannot remove it before the metadata is saved.
+func (b *Blob) Store(tx *gorm.DB, s storage.Storage) (model.Metadata, error) {
+	key := BlobKey(b.Checksum)
+	metadata := model.Metadata{
+		Path:     key,
+		Size:     b.Size,
+		Checksum: b.Checksum,
+	}
+
+	if err := LockBlob(tx, key); err != nil {
+		return metadata, err
+	}
+
+	if ok, err := s.Exists(key); err != nil || ok {
+		return metadata, err
+	}
+
+	return metadata, s.Put(key, bytes.NewReader(b.content), b.Size)
+}
+
+// RemoveBlob removes the blob stored under key unless a turn still references
+// it
+func RemoveBlob(tx *gorm.DB, s storage.Storage, key string) error {
+	if err := LockBlob(tx, key); err != nil {
+		return err
+	}
+
+	var refs int64
+	err := tx.
+		Model(&model.Metadata{}).
+		Where("path = ? and turn_id is not null", key).
+		Count(&refs).
+		Error
+	if err != nil || refs > 0 {
+		return err
+	}
+
+	return s.Remove(key)
+}
+
+// BlobKey is where the blob with the given checksum is stored
+func BlobKey(checksum string) string {
+	return path.Join("blobs", checksum[:2], checksum+".zip")
+}
+
+// LockBlob serializes, until tx ends, the transactions adding or removing
+// references to the blob stored under key
+func LockBlob(tx *gorm.DB, key string) error {
+	return tx.Exec("select pg_advisory_xact_lock(hashtext(?))", key).Error
+}
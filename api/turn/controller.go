@@ -1,10 +1,17 @@
 package turn
 
 import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
-	"os"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/alarmfox/game-repository/api"
 )
@@ -13,10 +20,14 @@ type Service interface {
 	CreateBulk(request *CreateRequest) ([]Turn, error)
 	FindById(id int64) (Turn, error)
 	Delete(id int64) error
-	Update(id int64, request *UpdateRequest) (Turn, error)
-	FindByRound(id int64) ([]Turn, error)
-	SaveFile(id int64, r io.Reader) error
-	GetFile(id int64) (string, *os.File, error)
+	Update(id int64, request *UpdateRequest, p api.Precondition) (Turn, error)
+	Patch(id int64, request *PatchRequest, p api.Precondition) (Turn, error)
+	FindByFilter(f *Filter, p api.PaginationParams) ([]Turn, int64, error)
+	SaveFile(id int64, r io.Reader) (FileVersion, error)
+	GetFile(id int64, version int) (FileVersion, io.ReadSeekCloser, error)
+	FindVersions(id int64) ([]FileVersion, error)
+	FindEntries(id int64, version int) ([]FileEntry, error)
+	GetEntry(id int64, version int, name string) (FileEntry, io.ReadCloser, error)
 }
 
 type Controller struct {
@@ -56,12 +67,32 @@ func (tc *Controller) Update(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	turn, err := tc.service.Update(id.AsInt64(), &request)
+	turn, err := tc.service.Update(id.AsInt64(), &request, api.IfMatch(r))
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, turn)
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(turn.UpdatedAt), turn)
+}
+
+func (tc *Controller) Patch(w http.ResponseWriter, r *http.Request) error {
+
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	request, err := api.FromJsonBody[PatchRequest](r.Body)
+	if err != nil {
+		return err
+	}
+
+	turn, err := tc.service.Patch(id.AsInt64(), &request, api.IfMatch(r))
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(turn.UpdatedAt), turn)
 }
 
 func (tc *Controller) FindByID(w http.ResponseWriter, r *http.Request) error {
@@ -77,7 +108,7 @@ func (tc *Controller) FindByID(w http.ResponseWriter, r *http.Request) error {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, turn)
+	return api.WriteEntity(w, r, http.StatusOK, api.ETag(turn.UpdatedAt), turn)
 
 }
 
@@ -102,13 +133,27 @@ func (tc *Controller) Upload(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	if err := tc.service.SaveFile(id.AsInt64(), r.Body); err != nil {
+	version, err := tc.service.SaveFile(id.AsInt64(), r.Body)
+	if err != nil {
 		return api.MakeHttpError(err)
 	}
 	defer r.Body.Close()
 
-	w.WriteHeader(http.StatusOK)
-	return nil
+	return api.WriteJson(w, http.StatusOK, version)
+}
+
+func (tc *Controller) ListVersions(w http.ResponseWriter, r *http.Request) error {
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	versions, err := tc.service.FindVersions(id.AsInt64())
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, versions)
 }
 
 func (tc *Controller) Download(w http.ResponseWriter, r *http.Request) error {
@@ -117,30 +162,228 @@ func (tc *Controller) Download(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	fname, f, err := tc.service.GetFile(id.AsInt64())
+	version, err := api.FromUrlQuery[VersionType](r, "version", 0)
+	if err != nil {
+		return err
+	}
+
+	v, f, err := tc.service.GetFile(id.AsInt64(), version.AsInt())
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 	defer f.Close()
 
+	fname := fmt.Sprintf("%d-%d.zip", id, v.Version)
+
+	// files uploaded before checksums were recorded have no digest
+	if v.Checksum != "" {
+		sum, err := hex.DecodeString(v.Checksum)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", fmt.Sprintf("%q", v.Checksum))
+		w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum))
+	}
+
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fname))
-	if _, err := io.Copy(w, f); err != nil {
+	http.ServeContent(w, r, fname, v.UploadedAt, f)
+	return nil
+}
+
+func (tc *Controller) ListEntries(w http.ResponseWriter, r *http.Request) error {
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
 		return err
 	}
+
+	version, err := api.FromUrlQuery[VersionType](r, "version", 0)
+	if err != nil {
+		return err
+	}
+
+	entries, err := tc.service.FindEntries(id.AsInt64(), version.AsInt())
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+
+	return api.WriteJson(w, http.StatusOK, entries)
+}
+
+func (tc *Controller) DownloadEntry(w http.ResponseWriter, r *http.Request) error {
+	id, err := api.FromUrlParams[KeyType](r, "id")
+	if err != nil {
+		return err
+	}
+
+	name, err := api.FromUrlParams[EntryPathType](r, "*")
+	if err != nil {
+		return err
+	}
+
+	version, err := api.FromUrlQuery[VersionType](r, "version", 0)
+	if err != nil {
+		return err
+	}
+
+	entry, rc, err := tc.service.GetEntry(id.AsInt64(), version.AsInt(), name.AsString())
+	if err != nil {
+		return api.MakeHttpError(err)
+	}
+	defer rc.Close()
+
+	br := bufio.NewReader(rc)
+	head, _ := br.Peek(512)
+
+	// entries are uploaded by players: browsers must neither guess their type
+	// nor run them as active content in the origin of the api
+	w.Header().Set("Content-Type", entryContentType(entry.Name, head))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", path.Base(entry.Name)))
+	if !entry.ModifiedAt.IsZero() {
+		w.Header().Set("Last-Modified", entry.ModifiedAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	// headers are already sent: the transfer is aborted, so that clients do
+	// not take a truncated file as complete
+	if _, err := io.Copy(w, br); err != nil {
+		log.Printf("cannot send %s of turn %d: %v", entry.Name, id.AsInt64(), err)
+		panic(http.ErrAbortHandler)
+	}
 	return nil
 }
 
+// inlineTypes are the media types of entries that are sent as they are.
+// Browsers display them without running scripts.
+var inlineTypes = map[string]bool{
+	"text/plain":       true,
+	"application/json": true,
+	"image/gif":        true,
+	"image/jpeg":       true,
+	"image/png":        true,
+	"image/webp":       true,
+}
+
+// entryContentType is the type of the entry called name starting with head.
+// The type is guessed from the extension or, when unknown, from the content.
+// Types outside of inlineTypes are sent as plain text or as raw bytes.
+func entryContentType(name string, head []byte) string {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && inlineTypes[mediaType] {
+		return contentType
+	}
+	if strings.HasPrefix(http.DetectContentType(head), "text/") {
+		return "text/plain; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
 func (tc *Controller) List(w http.ResponseWriter, r *http.Request) error {
-	id, err := api.FromUrlQuery(r, "roundId", KeyType(10))
+	roundId, err := api.FromUrlQuery[KeyType](r, "roundId", 0)
+	if err != nil {
+		return err
+	}
+
+	accountId, err := api.FromUrlQuery[AccountIdType](r, "accountId", "")
+	if err != nil {
+		return err
+	}
+
+	state, err := api.FromUrlQuery[StateFilter](r, "state", "")
+	if err != nil {
+		return err
+	}
+
+	isWinner, err := api.FromUrlOptionalQuery[BoolType](r, "isWinner")
+	if err != nil {
+		return err
+	}
+
+	scores, err := scoreFilter(r)
+	if err != nil {
+		return err
+	}
 
+	sort, err := api.FromUrlQuery(r, "sort", api.SortParams{Field: "id"})
 	if err != nil {
 		return err
 	}
-	turns, err := tc.service.FindByRound(id.AsInt64())
+
+	if err := api.SortColumn(sort, sortColumns); err != nil {
+		return err
+	}
+
+	page, err := api.FromUrlQuery[KeyType](r, "page", 1)
+	if err != nil {
+		return err
+	}
+
+	pageSize, err := api.FromUrlQuery[KeyType](r, "pageSize", 10)
+	if err != nil {
+		return err
+	}
+
+	f := Filter{
+		RoundID:   roundId.AsInt64(),
+		AccountID: accountId.AsString(),
+		IsWinner:  (*bool)(isWinner),
+		State:     state,
+		Scores:    scores,
+		Sort:      sort,
+	}
+
+	pp := api.PaginationParams{
+		Page:     page.AsInt64(),
+		PageSize: pageSize.AsInt64(),
+	}
+
+	turns, count, err := tc.service.FindByFilter(&f, pp)
 	if err != nil {
 		return api.MakeHttpError(err)
 	}
 
-	return api.WriteJson(w, http.StatusOK, turns)
+	return api.WriteJson(w, http.StatusOK, api.MakePaginatedResponse(turns, count, pp))
+}
+
+// scoreFilter reads the bounds on the scores of the listed turns
+func scoreFilter(r *http.Request) (ScoreFilter, error) {
+	minLineCoverage, err := api.FromUrlOptionalQuery[FloatType](r, "minLineCoverage")
+	if err != nil {
+		return ScoreFilter{}, err
+	}
+
+	minBranchCoverage, err := api.FromUrlOptionalQuery[FloatType](r, "minBranchCoverage")
+	if err != nil {
+		return ScoreFilter{}, err
+	}
+
+	minMutationScore, err := api.FromUrlOptionalQuery[FloatType](r, "minMutationScore")
+	if err != nil {
+		return ScoreFilter{}, err
+	}
+
+	minTests, err := api.FromUrlOptionalQuery[KeyType](r, "minTests")
+	if err != nil {
+		return ScoreFilter{}, err
+	}
+
+	compiled, err := api.FromUrlOptionalQuery[BoolType](r, "compiled")
+	if err != nil {
+		return ScoreFilter{}, err
+	}
+
+	return ScoreFilter{
+		MinLineCoverage:   (*float64)(minLineCoverage),
+		MinBranchCoverage: (*float64)(minBranchCoverage),
+		MinMutationScore:  (*float64)(minMutationScore),
+		MinTests:          (*int64)(minTests),
+		Compiled:          (*bool)(compiled),
+	}, nil
 }
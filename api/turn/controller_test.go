@@ -3,12 +3,15 @@ package turn
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/alarmfox/game-repository/api"
@@ -19,33 +22,61 @@ import (
 
 type ControllerSuite struct {
 	suite.Suite
-	tServer *httptest.Server
-	tmpDir  string
+	tServer  *httptest.Server
+	tmpDir   string
+	checksum string
 }
 
 func (suite *ControllerSuite) SetupSuite() {
 	tr := new(MockedRepository)
+	tmpDir, err := os.MkdirTemp("", "")
+	suite.Require().NoError(err)
+	suite.tmpDir = tmpDir
 	f, err := os.CreateTemp(suite.tmpDir, "")
 	suite.NoError(err)
 	_, err = f.Write([]byte("hello"))
 	suite.NoError(err)
+	sum := sha256.Sum256([]byte("hello"))
+	suite.checksum = hex.EncodeToString(sum[:])
 
 	tr.
 		On("GetFile",
-			int64(1)).
-		Return(f.Name(), f, nil).
+			int64(1), mock.MatchedBy(func(v int) bool { return v <= 1 })).
+		Return(FileVersion{Version: 1, Checksum: suite.checksum}, f, nil).
 		On("GetFile",
-			mock.MatchedBy(func(id int64) bool { return id != 1 })).
-		Return("", nil, api.ErrNotFound).
-		On("SaveFile",
-			int64(1),
-			mock.Anything).
-		Return(nil).
+			int64(1), mock.MatchedBy(func(v int) bool { return v > 1 })).
+		Return(FileVersion{}, nil, api.ErrNotFound).
+		On("GetFile",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }), mock.Anything).
+		Return(FileVersion{}, nil, api.ErrNotFound).
+		On("SaveFile", int64(1), mock.Anything).
+		Return(FileVersion{Version: 1}, nil).
 		On("SaveFile",
 			mock.MatchedBy(func(id int64) bool { return id != 1 }),
 			mock.Anything).
-		Return(api.ErrNotFound).
-		On("CreateBulk", &CreateRequest{RoundId: 1}).
+		Return(nil, api.ErrNotFound).
+		On("FindVersions", int64(1)).
+		Return([]FileVersion{{Version: 1}, {Version: 2}}, nil).
+		On("FindVersions",
+			mock.MatchedBy(func(id int64) bool { return id != 1 })).
+		Return(nil, api.ErrNotFound).
+		On("FindEntries", int64(1), mock.Anything).
+		Return([]FileEntry{{Name: "src/test/FooTest.java"}, {Name: "README.md"}}, nil).
+		On("FindEntries",
+			mock.MatchedBy(func(id int64) bool { return id != 1 }), mock.Anything).
+		Return(nil, api.ErrNotFound).
+		On("GetEntry", int64(1), mock.Anything, "src/test/FooTest.java").
+		Return(FileEntry{Name: "src/test/FooTest.java", Size: int64(len(entryContent))},
+			io.NopCloser(strings.NewReader(entryContent)), nil).
+		On("GetEntry", int64(1), mock.Anything, "index.html").
+		Return(FileEntry{Name: "index.html", Size: int64(len(htmlEntryContent))},
+			io.NopCloser(strings.NewReader(htmlEntryContent)), nil).
+		On("GetEntry", mock.Anything, mock.Anything,
+			mock.MatchedBy(func(name string) bool {
+				return name != "src/test/FooTest.java" && name != "index.html"
+			})).
+		Return(FileEntry{}, nil, api.ErrNotFound).
+		On("CreateBulk", &CreateRequest{RoundId: 1, Players: []string{"a"}}).
 		Return([]Turn{}, nil).
 		On("CreateBulk", mock.MatchedBy(func(r *CreateRequest) bool { return r.RoundId != 1 })).
 		Return(nil, api.ErrNotFound).
@@ -59,26 +90,36 @@ func (suite *ControllerSuite) SetupSuite() {
 		On("Delete",
 			mock.MatchedBy(func(id int64) bool { return id != 1 })).
 		Return(api.ErrNotFound).
-		On("Update", int64(1), &UpdateRequest{IsWinner: true, Scores: "a"}).
+		On("Update", int64(1), mock.MatchedBy(func(r *UpdateRequest) bool { return r.IsWinner && r.Scores != nil }), api.Precondition("")).
 		Return(Turn{}, nil).
+		On("Patch", int64(1),
+			mock.MatchedBy(func(r *PatchRequest) bool { return r.Scores.Null && r.IsWinner.Set && !r.IsWinner.Value }),
+			api.Precondition("")).
+		Return(Turn{ID: 1}, nil).
+		On("Patch", mock.MatchedBy(func(id int64) bool { return id != 1 }),
+			mock.Anything, mock.Anything).
+		Return(nil, api.ErrNotFound).
 		On("Update", mock.MatchedBy(func(id int64) bool { return id != 1 }),
-			&UpdateRequest{IsWinner: true, Scores: "a"}).
+			mock.MatchedBy(func(r *UpdateRequest) bool { return r.IsWinner && r.Scores != nil }), mock.Anything).
 		Return(nil, api.ErrNotFound).
-		On("FindByRound", int64(1)).
-		Return([]Turn{}, nil).
-		On("FindByRound", mock.MatchedBy(func(id int64) bool { return id != 1 })).
-		Return(nil, api.ErrNotFound)
+		On("FindByFilter", mock.MatchedBy(func(f *Filter) bool { return f.RoundID <= 1 }), mock.Anything).
+		Return([]Turn{}, int64(0), nil).
+		On("FindByFilter", mock.MatchedBy(func(f *Filter) bool { return f.RoundID > 1 }), mock.Anything).
+		Return(nil, int64(0), api.ErrNotFound)
 
-	suite.tmpDir = os.TempDir()
 	controller := NewController(tr)
 
 	r := chi.NewMux()
 
 	r.Get("/{id}/files", api.HandlerFunc(controller.Download))
+	r.Get("/{id}/files/versions", api.HandlerFunc(controller.ListVersions))
+	r.Get("/{id}/files/entries", api.HandlerFunc(controller.ListEntries))
+	r.Get("/{id}/files/entries/*", api.HandlerFunc(controller.DownloadEntry))
 	r.Put("/{id}/files", api.HandlerFunc(controller.Upload))
 	r.Post("/", api.HandlerFunc(controller.Create))
 	r.Get("/", api.HandlerFunc(controller.List))
 	r.Put("/{id}", api.HandlerFunc(controller.Update))
+	r.Patch("/{id}", api.HandlerFunc(controller.Patch))
 	r.Delete("/{id}", api.HandlerFunc(controller.Delete))
 	r.Get("/{id}", api.HandlerFunc(controller.FindByID))
 
@@ -135,12 +176,22 @@ func (suite *ControllerSuite) TestCreate() {
 		{
 			Name:           "T02-05-TurnCreated",
 			ExpectedStatus: http.StatusCreated,
-			Body:           `{"roundId": 1, "playerId": 1}`,
+			Body:           `{"roundId": 1, "players": ["a"]}`,
 		},
 		{
 			Name:           "T02-06-RoundNotExists",
 			ExpectedStatus: http.StatusNotFound,
-			Body:           `{"turnId": 2}`,
+			Body:           `{"roundId": 2, "players": ["a"]}`,
+		},
+		{
+			Name:           "T02-20-NoPlayers",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"roundId": 1, "players": []}`,
+		},
+		{
+			Name:           "T02-21-DuplicatedPlayers",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"roundId": 1, "players": ["a", "a"]}`,
 		},
 	}
 	for _, tc := range tcs {
@@ -208,13 +259,13 @@ func (suite *ControllerSuite) TestUpdate() {
 		{
 			Name:           "T02-10-BadId",
 			ExpectedStatus: http.StatusBadRequest,
-			Body:           `{"scores": "a", "isWinner": true}`,
+			Body:           `{"scores": {"lineCoverage": 80.5, "tests": 12, "compiled": true}, "isWinner": true}`,
 			Id:             `1a`,
 		},
 		{
 			Name:           "T02-11-TurnUpdated",
 			ExpectedStatus: http.StatusOK,
-			Body:           `{"scores": "a", "isWinner": true}`,
+			Body:           `{"scores": {"lineCoverage": 80.5, "tests": 12, "compiled": true}, "isWinner": true}`,
 			Id:             `1`,
 		},
 		{
@@ -223,10 +274,22 @@ func (suite *ControllerSuite) TestUpdate() {
 			Body:           `{"order"}`,
 			Id:             `1`,
 		},
+		{
+			Name:           "T02-26-CoverageOutOfRange",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"scores": {"lineCoverage": 120, "tests": -1}, "isWinner": true}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T02-27-FreeFormScores",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"scores": "10,20,30", "isWinner": true}`,
+			Id:             `1`,
+		},
 		{
 			Name:           "T02-13-TurnNotFound",
 			ExpectedStatus: http.StatusNotFound,
-			Body:           `{"scores": "a", "isWinner": true}`,
+			Body:           `{"scores": {"lineCoverage": 80.5, "tests": 12, "compiled": true}, "isWinner": true}`,
 			Id:             `11`,
 		},
 	}
@@ -247,6 +310,50 @@ func (suite *ControllerSuite) TestUpdate() {
 	}
 
 }
+func (suite *ControllerSuite) TestPatch() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		Body           string
+		Id             string
+	}{
+		{
+			Name:           "T02-17-TurnPatched",
+			ExpectedStatus: http.StatusOK,
+			Body:           `{"isWinner": false, "scores": null}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T02-18-NullWinner",
+			ExpectedStatus: http.StatusBadRequest,
+			Body:           `{"isWinner": null}`,
+			Id:             `1`,
+		},
+		{
+			Name:           "T02-19-TurnNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			Body:           `{"scores": null}`,
+			Id:             `11`,
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s", suite.tServer.URL, tc.Id)
+			req, err := http.NewRequest(http.MethodPatch,
+				url,
+				bytes.NewBufferString(tc.Body))
+			suite.NoError(err)
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+
+			res, err := http.DefaultClient.Do(req)
+			suite.NoError(err)
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+		})
+	}
+}
+
 func (suite *ControllerSuite) TestList() {
 
 	tcs := []struct {
@@ -257,26 +364,59 @@ func (suite *ControllerSuite) TestList() {
 		{
 			Name:           "T02-14-TurnsFound",
 			ExpectedStatus: http.StatusOK,
-			Input:          "1",
+			Input:          "roundId=1",
 		},
 		{
 			Name:           "T02-15-ErrBadId",
 			ExpectedStatus: http.StatusBadRequest,
-			Input:          "invalid",
+			Input:          "roundId=invalid",
 		},
 		{
 			Name:           "T02-16-RoundNotFound",
 			ExpectedStatus: http.StatusNotFound,
-			Input:          "2",
+			Input:          "roundId=2",
+		},
+		{
+			Name:           "T02-22-AllRounds",
+			ExpectedStatus: http.StatusOK,
+			Input:          "",
+		},
+		{
+			Name:           "T02-23-ByPlayerAndState",
+			ExpectedStatus: http.StatusOK,
+			Input:          "accountId=player&isWinner=true&state=closed",
+		},
+		{
+			Name:           "T02-24-InvalidState",
+			ExpectedStatus: http.StatusBadRequest,
+			Input:          "state=pending",
+		},
+		{
+			Name:           "T02-25-InvalidWinner",
+			ExpectedStatus: http.StatusBadRequest,
+			Input:          "isWinner=maybe",
+		},
+		{
+			Name:           "T02-28-ByScores",
+			ExpectedStatus: http.StatusOK,
+			Input:          "minLineCoverage=80&minTests=1&compiled=true&sort=lineCoverage:desc",
+		},
+		{
+			Name:           "T02-29-UnsupportedSort",
+			ExpectedStatus: http.StatusBadRequest,
+			Input:          "sort=scores:asc",
+		},
+		{
+			Name:           "T02-30-InvalidBound",
+			ExpectedStatus: http.StatusBadRequest,
+			Input:          "minMutationScore=high",
 		},
 	}
 
 	for _, tc := range tcs {
 		tc := tc
 		suite.T().Run(tc.Name, func(t *testing.T) {
-			q := url.Values{}
-			q.Add("roundId", tc.Input)
-			url := fmt.Sprintf("%s?%s", suite.tServer.URL, q.Encode())
+			url := fmt.Sprintf("%s?%s", suite.tServer.URL, tc.Input)
 			res, err := http.Get(url)
 			suite.NoError(err)
 			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
@@ -347,23 +487,50 @@ func (suite *ControllerSuite) TestDownload() {
 		Name           string
 		ExpectedStatus int
 		TurnID         string
+		Query          string
+		IfNoneMatch    string
 	}{
 		{
 			Name:           "T35-DownloadOK",
 			ExpectedStatus: http.StatusOK,
 			TurnID:         "1",
 		},
+		{
+			Name:           "T02-36-NotModified",
+			ExpectedStatus: http.StatusNotModified,
+			TurnID:         "1",
+			IfNoneMatch:    fmt.Sprintf("%q", suite.checksum),
+		},
 		{
 			Name:           "T36-TurnNotFound",
 			ExpectedStatus: http.StatusNotFound,
 			TurnID:         "21",
 		},
+		{
+			Name:           "T02-31-VersionNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			TurnID:         "1",
+			Query:          "version=3",
+		},
+		{
+			Name:           "T02-32-InvalidVersion",
+			ExpectedStatus: http.StatusBadRequest,
+			TurnID:         "1",
+			Query:          "version=0",
+		},
 	}
 	for _, tc := range tcs {
 		tc := tc
 		suite.T().Run(tc.Name, func(t *testing.T) {
 
-			res, err := http.Get(fmt.Sprintf("%s/%s/files", suite.tServer.URL, tc.TurnID))
+			req, err := http.NewRequest(http.MethodGet,
+				fmt.Sprintf("%s/%s/files?%s", suite.tServer.URL, tc.TurnID, tc.Query), nil)
+			suite.NoError(err)
+			if tc.IfNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.IfNoneMatch)
+			}
+
+			res, err := http.DefaultClient.Do(req)
 			suite.NoError(err)
 			defer res.Body.Close()
 
@@ -371,11 +538,169 @@ func (suite *ControllerSuite) TestDownload() {
 
 			suite.NoError(err)
 			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			if res.StatusCode == http.StatusOK {
+				suite.Equal(fmt.Sprintf("%q", suite.checksum), res.Header.Get("ETag"), tc.Name)
+				suite.True(strings.HasPrefix(res.Header.Get("Digest"), "sha-256="), tc.Name)
+			}
+
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestListVersions() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		TurnID         string
+		Expected       int
+	}{
+		{
+			Name:           "T02-33-VersionsListed",
+			ExpectedStatus: http.StatusOK,
+			TurnID:         "1",
+			Expected:       2,
+		},
+		{
+			Name:           "T02-34-TurnNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			TurnID:         "21",
+		},
+		{
+			Name:           "T02-35-BadTurnID",
+			ExpectedStatus: http.StatusBadRequest,
+			TurnID:         "a21",
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s/%s/files/versions", suite.tServer.URL, tc.TurnID))
+			suite.NoError(err)
+			defer res.Body.Close()
+
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+
+			var versions []FileVersion
+			suite.NoError(json.NewDecoder(res.Body).Decode(&versions))
+			suite.Len(versions, tc.Expected, tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestListEntries() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		TurnID         string
+		Query          string
+		Expected       int
+	}{
+		{
+			Name:           "T02-37-EntriesListed",
+			ExpectedStatus: http.StatusOK,
+			TurnID:         "1",
+			Expected:       2,
+		},
+		{
+			Name:           "T02-38-TurnNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			TurnID:         "21",
+		},
+		{
+			Name:           "T02-39-InvalidVersion",
+			ExpectedStatus: http.StatusBadRequest,
+			TurnID:         "1",
+			Query:          "version=a",
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s/%s/files/entries?%s", suite.tServer.URL, tc.TurnID, tc.Query))
+			suite.NoError(err)
+			defer res.Body.Close()
+
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			if res.StatusCode != http.StatusOK {
+				return
+			}
+
+			var entries []FileEntry
+			suite.NoError(json.NewDecoder(res.Body).Decode(&entries))
+			suite.Len(entries, tc.Expected, tc.Name)
+		})
+	}
+}
+
+func (suite *ControllerSuite) TestDownloadEntry() {
+
+	tcs := []struct {
+		Name           string
+		ExpectedStatus int
+		TurnID         string
+		Path           string
+	}{
+		{
+			Name:           "T02-40-EntryDownloaded",
+			ExpectedStatus: http.StatusOK,
+			TurnID:         "1",
+			Path:           "src/test/FooTest.java",
+		},
+		{
+			Name:           "T02-41-EntryNotFound",
+			ExpectedStatus: http.StatusNotFound,
+			TurnID:         "1",
+			Path:           "src/test/BarTest.java",
+		},
+		{
+			Name:           "T02-42-BadTurnID",
+			ExpectedStatus: http.StatusBadRequest,
+			TurnID:         "a1",
+			Path:           "src/test/FooTest.java",
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			res, err := http.Get(fmt.Sprintf("%s/%s/files/entries/%s", suite.tServer.URL, tc.TurnID, tc.Path))
+			suite.NoError(err)
+			defer res.Body.Close()
+
+			suite.Equal(tc.ExpectedStatus, res.StatusCode, tc.Name)
+			if res.StatusCode != http.StatusOK {
+				return
+			}
 
+			body, err := io.ReadAll(res.Body)
+			suite.NoError(err)
+			suite.Equal(entryContent, string(body), tc.Name)
+			// the type of .java files depends on the mime types of the system
+			suite.True(strings.HasPrefix(res.Header.Get("Content-Type"), "text/"), tc.Name)
+			suite.Equal("nosniff", res.Header.Get("X-Content-Type-Options"), tc.Name)
 		})
 	}
 }
 
+func (suite *ControllerSuite) TestDownloadEntryActiveContent() {
+	res, err := http.Get(fmt.Sprintf("%s/1/files/entries/index.html", suite.tServer.URL))
+	suite.Require().NoError(err)
+	defer res.Body.Close()
+
+	suite.Equal(http.StatusOK, res.StatusCode)
+	suite.Equal("text/plain; charset=utf-8", res.Header.Get("Content-Type"))
+	suite.Equal("nosniff", res.Header.Get("X-Content-Type-Options"))
+	suite.Equal("sandbox", res.Header.Get("Content-Security-Policy"))
+
+	body, err := io.ReadAll(res.Body)
+	suite.NoError(err)
+	suite.Equal(htmlEntryContent, string(body))
+}
+
 func (suite *ControllerSuite) TearDownSuite() {
 	defer os.RemoveAll(suite.tmpDir)
 	defer suite.tServer.Close()
@@ -384,6 +709,12 @@ func TestTurnControllerSuite(t *testing.T) {
 	suite.Run(t, new(ControllerSuite))
 }
 
+// entryContent is the file returned by the mocked repository from turn zips
+const entryContent = "public class FooTest {}"
+
+// htmlEntryContent is a page uploaded in turn zips that must not be rendered
+const htmlEntryContent = "<html><script>alert(document.cookie)</script></html>"
+
 type MockedRepository struct {
 	mock.Mock
 }
@@ -413,18 +744,18 @@ func (m *MockedRepository) Delete(id int64) error {
 	return args.Error(0)
 }
 
-func (m *MockedRepository) FindByRound(id int64) ([]Turn, error) {
-	args := m.Called(id)
+func (m *MockedRepository) FindByFilter(f *Filter, p api.PaginationParams) ([]Turn, int64, error) {
+	args := m.Called(f, p)
 	v := args.Get(0)
 
 	if v == nil {
-		return nil, args.Error(1)
+		return nil, args.Get(1).(int64), args.Error(2)
 	}
-	return v.([]Turn), args.Error(1)
+	return v.([]Turn), args.Get(1).(int64), args.Error(2)
 }
 
-func (m *MockedRepository) Update(id int64, request *UpdateRequest) (Turn, error) {
-	args := m.Called(id, request)
+func (m *MockedRepository) Update(id int64, request *UpdateRequest, p api.Precondition) (Turn, error) {
+	args := m.Called(id, request, p)
 	v := args.Get(0)
 
 	if v == nil {
@@ -433,19 +764,54 @@ func (m *MockedRepository) Update(id int64, request *UpdateRequest) (Turn, error
 	return v.(Turn), args.Error(1)
 }
 
-func (m *MockedRepository) SaveFile(id int64, r io.Reader) error {
+func (m *MockedRepository) SaveFile(id int64, r io.Reader) (FileVersion, error) {
 	args := m.Called(id, r)
-	return args.Error(0)
+	v := args.Get(0)
+
+	if v == nil {
+		return FileVersion{}, args.Error(1)
+	}
+	return v.(FileVersion), args.Error(1)
 }
 
-func (m *MockedRepository) GetFile(id int64) (string, *os.File, error) {
+func (m *MockedRepository) FindVersions(id int64) ([]FileVersion, error) {
 	args := m.Called(id)
+	v := args.Get(0)
+
+	if v == nil {
+		return nil, args.Error(1)
+	}
+	return v.([]FileVersion), args.Error(1)
+}
+
+func (m *MockedRepository) GetFile(id int64, version int) (FileVersion, io.ReadSeekCloser, error) {
+	args := m.Called(id, version)
 	v := args.Get(1)
 
 	if v == nil {
-		return "", nil, args.Error(2)
+		return FileVersion{}, nil, args.Error(2)
+	}
+	return args.Get(0).(FileVersion), v.(io.ReadSeekCloser), args.Error(2)
+}
+
+func (m *MockedRepository) FindEntries(id int64, version int) ([]FileEntry, error) {
+	args := m.Called(id, version)
+	v := args.Get(0)
+
+	if v == nil {
+		return nil, args.Error(1)
 	}
-	return args.String(0), v.(*os.File), args.Error(2)
+	return v.([]FileEntry), args.Error(1)
+}
+
+func (m *MockedRepository) GetEntry(id int64, version int, name string) (FileEntry, io.ReadCloser, error) {
+	args := m.Called(id, version, name)
+	v := args.Get(1)
+
+	if v == nil {
+		return FileEntry{}, nil, args.Error(2)
+	}
+	return args.Get(0).(FileEntry), v.(io.ReadCloser), args.Error(2)
 }
 
 func generateValidZipContent(t *testing.T, content []byte) io.Reader {
@@ -472,3 +838,13 @@ func generateValidZipContent(t *testing.T, content []byte) io.Reader {
 
 	return buf
 }
+
+func (m *MockedRepository) Patch(id int64, request *PatchRequest, p api.Precondition) (Turn, error) {
+	args := m.Called(id, request, p)
+	v := args.Get(0)
+
+	if v == nil {
+		return Turn{}, args.Error(1)
+	}
+	return v.(Turn), args.Error(1)
+}
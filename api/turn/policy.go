@@ -0,0 +1,174 @@
+package turn
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/alarmfox/game-repository/api"
+)
+
+// UploadPolicy restricts the content of uploaded turn files. Zero values
+// disable the corresponding rule; unsafe paths are always rejected.
+type UploadPolicy struct {
+	// MaxUncompressedSize is the maximum size in bytes of the extracted
+	// files
+	MaxUncompressedSize int64 `json:"maxUncompressedSize"`
+	// MaxEntries is the maximum number of files and directories
+	MaxEntries int `json:"maxEntries"`
+	// AllowedExtensions lists the extensions files may have, as in .java
+	AllowedExtensions []string `json:"allowedExtensions"`
+	// RequiredDirs lists the directories that must contain at least a file,
+	// as in src/test/java
+	RequiredDirs []string `json:"requiredDirs"`
+}
+
+// ZipRule checks an uploaded zip. Violations wrap api.ErrNotAZip and name
+// the rule.
+type ZipRule func(zr *zip.Reader) error
+
+// Rules is the validation pipeline of the policy. Cheap checks on the
+// central directory come first, so that archives are decompressed only when
+// everything else holds.
+func (p UploadPolicy) Rules() []ZipRule {
+	rules := []ZipRule{SafePaths}
+	if p.MaxEntries > 0 {
+		rules = append(rules, MaxEntries(p.MaxEntries))
+	}
+	if len(p.AllowedExtensions) > 0 {
+		rules = append(rules, AllowedExtensions(p.AllowedExtensions...))
+	}
+	if len(p.RequiredDirs) > 0 {
+		rules = append(rules, RequiredDirs(p.RequiredDirs...))
+	}
+	if p.MaxUncompressedSize > 0 {
+		rules = append(rules, MaxUncompressedSize(p.MaxUncompressedSize))
+	}
+	return rules
+}
+
+// Validate applies the rules of the policy to zr, stopping at the first
+// violation
+func (p UploadPolicy) Validate(zr *zip.Reader) error {
+	for _, rule := range p.Rules() {
+		if err := rule(zr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func zipViolation(rule, format string, a ...any) error {
+	return fmt.Errorf("%w: %s: %s", api.ErrNotAZip, rule, fmt.Sprintf(format, a...))
+}
+
+// SafePaths fails on entries with absolute paths or escaping the directory
+// the zip is extracted to
+func SafePaths(zr *zip.Reader) error {
+	for _, f := range zr.File {
+		name := strings.ReplaceAll(f.Name, `\`, "/")
+		if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+			return zipViolation("safePaths", "%q is an absolute path", f.Name)
+		}
+		for _, segment := range strings.Split(name, "/") {
+			if segment == ".." {
+				return zipViolation("safePaths", "%q escapes the archive", f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// MaxEntries fails on zips with more than n files and directories
+func MaxEntries(n int) ZipRule {
+	return func(zr *zip.Reader) error {
+		if len(zr.File) > n {
+			return zipViolation("maxEntries", "%d entries, at most %d are allowed", len(zr.File), n)
+		}
+		return nil
+	}
+}
+
+// AllowedExtensions fails on files whose extension is not in extensions.
+// Extensions are compared ignoring case.
+func AllowedExtensions(extensions ...string) ZipRule {
+	return func(zr *zip.Reader) error {
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+
+			ext := path.Ext(f.Name)
+			allowed := false
+			for _, e := range extensions {
+				if strings.EqualFold(ext, e) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return zipViolation("allowedExtensions", "%q must have one of the extensions %s", f.Name, strings.Join(extensions, ", "))
+			}
+		}
+		return nil
+	}
+}
+
+// RequiredDirs fails unless every directory in dirs contains at least a file
+func RequiredDirs(dirs ...string) ZipRule {
+	return func(zr *zip.Reader) error {
+		for _, dir := range dirs {
+			prefix := strings.Trim(dir, "/") + "/"
+			found := false
+			for _, f := range zr.File {
+				if !f.FileInfo().IsDir() && strings.HasPrefix(f.Name, prefix) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return zipViolation("requiredDirs", "no file in %s", prefix)
+			}
+		}
+		return nil
+	}
+}
+
+// MaxUncompressedSize fails on zips extracting to more than n bytes. Entries
+// are decompressed, since the sizes declared by the archive can be forged.
+func MaxUncompressedSize(n int64) ZipRule {
+	return func(zr *zip.Reader) error {
+		var declared uint64
+		for _, f := range zr.File {
+			declared += f.UncompressedSize64
+		}
+		if declared > uint64(n) {
+			return zipViolation("maxUncompressedSize", "%d bytes, at most %d are allowed", declared, n)
+		}
+
+		remaining := n
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", api.ErrNotAZip, f.Name, err)
+			}
+			written, err := io.CopyN(io.Discard, rc, remaining+1)
+			rc.Close()
+
+			remaining -= written
+			if remaining < 0 {
+				return zipViolation("maxUncompressedSize", "more than %d bytes", n)
+			}
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("%w: %s: %v", api.ErrNotAZip, f.Name, err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+package turn
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alarmfox/game-repository/api"
+	"github.com/stretchr/testify/suite"
+)
+
+type PolicySuite struct {
+	suite.Suite
+}
+
+func (suite *PolicySuite) TestValidate() {
+	policy := UploadPolicy{
+		MaxUncompressedSize: 1 << 10,
+		MaxEntries:          4,
+		AllowedExtensions:   []string{".java"},
+		RequiredDirs:        []string{"src/test"},
+	}
+
+	tcs := []struct {
+		Name    string
+		Files   map[string]string
+		Policy  UploadPolicy
+		Rule    string
+		IsValid bool
+	}{
+		{
+			Name:    "T02-43-ValidZip",
+			Files:   map[string]string{"src/test/FooTest.java": "class FooTest {}", "src/test/": ""},
+			Policy:  policy,
+			IsValid: true,
+		},
+		{
+			Name:    "T02-44-NoPolicy",
+			Files:   map[string]string{"notes.txt": "hello"},
+			IsValid: true,
+		},
+		{
+			Name:   "T02-45-Traversal",
+			Files:  map[string]string{"src/test/../../FooTest.java": ""},
+			Policy: policy,
+			Rule:   "safePaths",
+		},
+		{
+			Name:  "T02-46-AbsolutePath",
+			Files: map[string]string{"/etc/FooTest.java": ""},
+			Rule:  "safePaths",
+		},
+		{
+			Name:  "T02-47-WindowsAbsolutePath",
+			Files: map[string]string{`C:\FooTest.java`: ""},
+			Rule:  "safePaths",
+		},
+		{
+			Name: "T02-48-TooManyEntries",
+			Files: map[string]string{
+				"src/test/A.java": "", "src/test/B.java": "", "src/test/C.java": "",
+				"src/test/D.java": "", "src/test/E.java": "",
+			},
+			Policy: policy,
+			Rule:   "maxEntries",
+		},
+		{
+			Name:   "T02-49-ExtensionNotAllowed",
+			Files:  map[string]string{"src/test/FooTest.java": "", "src/test/run.sh": ""},
+			Policy: policy,
+			Rule:   "allowedExtensions",
+		},
+		{
+			Name:   "T02-50-MissingDir",
+			Files:  map[string]string{"src/main/Foo.java": ""},
+			Policy: policy,
+			Rule:   "requiredDirs",
+		},
+		{
+			Name:   "T02-51-TooLarge",
+			Files:  map[string]string{"src/test/FooTest.java": strings.Repeat("a", 1<<20)},
+			Policy: policy,
+			Rule:   "maxUncompressedSize",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		suite.T().Run(tc.Name, func(t *testing.T) {
+			err := tc.Policy.Validate(makeZip(t, tc.Files))
+			if tc.IsValid {
+				suite.NoError(err, tc.Name)
+				return
+			}
+
+			suite.ErrorIs(err, api.ErrNotAZip, tc.Name)
+			suite.ErrorContains(err, tc.Rule, tc.Name)
+		})
+	}
+}
+
+func TestPolicySuite(t *testing.T) {
+	suite.Run(t, new(PolicySuite))
+}
+
+// makeZip builds a zip with the given files; names ending in a slash are
+// directories
+func makeZip(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for name, content := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
@@ -1,31 +1,30 @@
 package turn
 
 import (
-	"archive/zip"
 	"database/sql"
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path"
-	"path/filepath"
-	"strconv"
-	"time"
 
 	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/api/round"
 	"github.com/alarmfox/game-repository/model"
+	"github.com/alarmfox/game-repository/storage"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
 	db      *gorm.DB
-	dataDir string
+	storage storage.Storage
+	policy  UploadPolicy
 }
 
-func NewRepository(db *gorm.DB, dataDir string) *Repository {
+func NewRepository(db *gorm.DB, s storage.Storage, policy UploadPolicy) *Repository {
 	return &Repository{
 		db:      db,
-		dataDir: dataDir,
+		storage: s,
+		policy:  policy,
 	}
 }
 
@@ -37,13 +36,21 @@ func (tr *Repository) CreateBulk(r *CreateRequest) ([]Turn, error) {
 			err error
 		)
 
-		err = tx.Where(&model.Round{ID: r.RoundId}).
-			First(&model.Round{}).
+		var game model.Game
+		err = tx.
+			Joins("join rounds on rounds.game_id = games.id and rounds.deleted_at is null").
+			Where("rounds.id = ?", r.RoundId).
+			Clauses(clause.Locking{Strength: "SHARE", Table: clause.Table{Name: "games"}}).
+			First(&game).
 			Error
 		if err != nil {
 			return err
 		}
 
+		if game.Status != model.GameStatusStarted {
+			return fmt.Errorf("%w: game is not started", api.ErrInvalidState)
+		}
+
 		var ids []int64
 		err = tx.
 			Model(&model.Player{}).
@@ -79,16 +86,62 @@ func (tr *Repository) CreateBulk(r *CreateRequest) ([]Turn, error) {
 	return resp, api.MakeServiceError(err)
 }
 
-func (tr *Repository) Update(id int64, r *UpdateRequest) (Turn, error) {
+func (tr *Repository) Update(id int64, r *UpdateRequest, p api.Precondition) (Turn, error) {
+	return tr.update(id, r, p)
+}
 
-	var (
-		turn model.Turn = model.Turn{ID: id}
-		err  error
-	)
+func (tr *Repository) Patch(id int64, r *PatchRequest, p api.Precondition) (Turn, error) {
+	return tr.update(id, r.updates(), p)
+}
 
-	err = tr.db.Model(&turn).Updates(r).Error
+// update applies updates, a struct or a column map, to the turn when p is
+// satisfied and returns the turn as stored
+func (tr *Repository) update(id int64, updates any, p api.Precondition) (Turn, error) {
+	var turn model.Turn
 
-	return fromModel(&turn), api.MakeServiceError(err)
+	err := tr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("round_id").First(&turn, id).Error; err != nil {
+			return err
+		}
+
+		if _, err := round.LockGame(tx, turn.RoundID); err != nil {
+			return err
+		}
+
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&turn, id).
+			Error
+		if err != nil {
+			return err
+		}
+		open := turn.ClosedAt == nil
+
+		if !p.Matches(api.ETag(turn.UpdatedAt)) {
+			return api.ErrPreconditionFailed
+		}
+
+		if m, ok := updates.(map[string]any); !ok || len(m) > 0 {
+			if err := tx.Model(&turn).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.First(&turn, id).Error; err != nil {
+			return err
+		}
+
+		if open && turn.ClosedAt != nil {
+			return round.CloseIfPlayed(tx, turn.RoundID)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return Turn{}, api.MakeServiceError(err)
+	}
+
+	return fromModel(&turn), nil
 }
 
 func (tr *Repository) FindById(id int64) (Turn, error) {
@@ -101,18 +154,83 @@ func (tr *Repository) FindById(id int64) (Turn, error) {
 	return fromModel(&turn), api.MakeServiceError(err)
 }
 
-func (tr *Repository) FindByRound(id int64) ([]Turn, error) {
-	var turns []model.Turn
+// FindByFilter lists a page of the turns matching f, ordered by f.Sort and
+// then by id, along with the number of matching turns
+func (tr *Repository) FindByFilter(f *Filter, p api.PaginationParams) ([]Turn, int64, error) {
+	var (
+		turns []model.Turn
+		n     int64
+	)
+
+	err := tr.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.
+			Model(&model.Turn{}).
+			Scopes(withFilter(f))
+
+		if err := query.Count(&n).Error; err != nil {
+			return err
+		}
+
+		return query.
+			Scopes(api.WithSort(f.Sort, sortColumns), api.WithPagination(p)).
+			Order("turns.id asc").
+			Find(&turns).
+			Error
+	})
 
-	err := tr.db.
-		Where(&model.Turn{RoundID: id}).
-		Find(&turns).
-		Error
 	resp := make([]Turn, len(turns))
 	for i, turn := range turns {
 		resp[i] = fromModel(&turn)
 	}
-	return resp, api.MakeServiceError(err)
+	return resp, n, api.MakeServiceError(err)
+}
+
+func withFilter(f *Filter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f.RoundID != 0 {
+			db = db.Where("turns.round_id = ?", f.RoundID)
+		}
+
+		if f.AccountID != "" {
+			db = db.
+				Joins("join players on players.id = turns.player_id").
+				Where("players.account_id = ?", f.AccountID)
+		}
+
+		if f.IsWinner != nil {
+			db = db.Where("turns.is_winner = ?", *f.IsWinner)
+		}
+
+		switch f.State {
+		case stateOpen:
+			db = db.Where("turns.closed_at is null")
+		case stateClosed:
+			db = db.Where("turns.closed_at is not null")
+		}
+
+		return db.Scopes(withScores(f.Scores))
+	}
+}
+
+func withScores(f ScoreFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if f.MinLineCoverage != nil {
+			db = db.Where(scoreColumn("lineCoverage", "numeric")+" >= ?", *f.MinLineCoverage)
+		}
+		if f.MinBranchCoverage != nil {
+			db = db.Where(scoreColumn("branchCoverage", "numeric")+" >= ?", *f.MinBranchCoverage)
+		}
+		if f.MinMutationScore != nil {
+			db = db.Where(scoreColumn("mutationScore", "numeric")+" >= ?", *f.MinMutationScore)
+		}
+		if f.MinTests != nil {
+			db = db.Where(scoreColumn("tests", "bigint")+" >= ?", *f.MinTests)
+		}
+		if f.Compiled != nil {
+			db = db.Where(scoreColumn("compiled", "boolean")+" = ?", *f.Compiled)
+		}
+		return db
+	}
 }
 
 func (tr *Repository) Delete(id int64) error {
@@ -131,92 +249,210 @@ func (tr *Repository) Delete(id int64) error {
 
 }
 
-func (ts *Repository) SaveFile(id int64, r io.Reader) error {
+// SaveFile stores r as the next version of the files of the turn, uploaded by
+// the player of the turn. Zips violating the upload policy are rejected.
+func (ts *Repository) SaveFile(id int64, r io.Reader) (FileVersion, error) {
 	if r == nil {
-		return fmt.Errorf("%w: body is empty", api.ErrInvalidParam)
+		return FileVersion{}, fmt.Errorf("%w: body is empty", api.ErrInvalidParam)
 	}
-	err := ts.db.Transaction(func(tx *gorm.DB) error {
+
+	blob, err := NewBlob(r)
+	if err != nil {
+		return FileVersion{}, api.MakeServiceError(err)
+	}
+
+	zr, err := blob.OpenZip()
+	if err != nil {
+		return FileVersion{}, api.ErrNotAZip
+	}
+
+	if err := ts.policy.Validate(zr); err != nil {
+		return FileVersion{}, err
+	}
+
+	var metadata model.Metadata
+
+	err = ts.db.Transaction(func(tx *gorm.DB) error {
 		var (
-			err   error
-			round model.Round
+			err        error
+			turn       model.Turn
+			version    int
+			uploadedBy string
 		)
 
+		// concurrent uploads of the turn are numbered one at a time
 		err = tx.
-			Joins("join turns on turns.round_id = rounds.id where turns.id  = ?", id).
-			First(&round).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "turns"}}).
+			Joins("join rounds on rounds.id = turns.round_id and rounds.deleted_at is null").
+			Select("turns.id", "turns.player_id").
+			First(&turn, id).
 			Error
-
 		if err != nil {
 			return err
 		}
 
-		dst, err := os.CreateTemp("", "")
+		err = tx.
+			Model(&model.Metadata{}).
+			Select("coalesce(max(version), 0) + 1").
+			Where("turn_id = ?", id).
+			Scan(&version).
+			Error
 		if err != nil {
 			return err
 		}
-		defer os.Remove(dst.Name())
-		if _, err := io.Copy(dst, r); err != nil {
+
+		err = tx.
+			Model(&model.Player{}).
+			Select("account_id").
+			Where("id = ?", turn.PlayerID).
+			Scan(&uploadedBy).
+			Error
+		if err != nil {
 			return err
 		}
 
-		if zfile, err := zip.OpenReader(dst.Name()); err != nil {
-			return api.ErrNotAZip
-		} else {
-			zfile.Close()
+		metadata, err = blob.Store(tx, ts.storage)
+		if err != nil {
+			return err
 		}
 
-		year := time.Now().Year()
+		metadata.TurnID = sql.NullInt64{Int64: id, Valid: true}
+		metadata.Version = version
+		metadata.UploadedBy = uploadedBy
 
-		fname := path.Join(ts.dataDir,
-			strconv.FormatInt(int64(year), 10),
-			strconv.FormatInt(round.GameID, 10),
-			fmt.Sprintf("%d.zip", id),
-		)
+		return tx.Create(&metadata).Error
+	})
 
-		dir := path.Dir(fname)
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil && !errors.Is(err, os.ErrExist) {
-			return err
+	if err != nil {
+		if metadata.Path != "" {
+			ts.db.Transaction(func(tx *gorm.DB) error {
+				return RemoveBlob(tx, ts.storage, metadata.Path)
+			})
 		}
+		return FileVersion{}, api.MakeServiceError(err)
+	}
 
-		if err := os.Rename(dst.Name(), fname); err != nil {
+	return versionFromModel(&metadata), nil
+}
+
+// FindVersions lists the uploaded versions of the files of the turn, oldest
+// first
+func (ts *Repository) FindVersions(id int64) ([]FileVersion, error) {
+	var metadata []model.Metadata
+
+	err := ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&model.Turn{}, id).Error; err != nil {
 			return err
 		}
 
-		return tx.FirstOrCreate(
-			&model.Metadata{
-				TurnID: sql.NullInt64{Int64: id, Valid: true},
-				Path:   fname,
-			}).
+		return tx.
+			Where(&model.Metadata{TurnID: sql.NullInt64{Int64: id, Valid: true}}).
+			Order("version asc").
+			Find(&metadata).
 			Error
-
 	})
 
-	return api.MakeServiceError(err)
+	resp := make([]FileVersion, len(metadata))
+	for i, m := range metadata {
+		resp[i] = versionFromModel(&m)
+	}
 
+	return resp, api.MakeServiceError(err)
 }
 
-func (ts *Repository) GetFile(id int64) (string, *os.File, error) {
+// GetFile opens the given version of the files of the turn; version 0 is the
+// latest
+func (ts *Repository) GetFile(id int64, version int) (FileVersion, io.ReadSeekCloser, error) {
 	var (
 		metadata model.Metadata
 		err      error
 	)
 
 	err = ts.db.
-		Where(&model.Metadata{TurnID: sql.NullInt64{Int64: id, Valid: true}}).
+		Joins("join turns on turns.id = metadata.turn_id and turns.deleted_at is null").
+		Where(&model.Metadata{TurnID: sql.NullInt64{Int64: id, Valid: true}, Version: version}).
+		Order("metadata.version desc").
 		First(&metadata).
 		Error
 
 	if err != nil {
-		return "", nil, api.MakeServiceError(err)
+		return FileVersion{}, nil, api.MakeServiceError(err)
 	}
 
-	f, err := os.Open(metadata.Path)
+	f, err := ts.storage.Open(metadata.Path)
 
-	if errors.Is(err, os.ErrNotExist) {
-		return "", nil, api.ErrNotFound
+	if errors.Is(err, storage.ErrNotExist) {
+		return FileVersion{}, nil, api.ErrNotFound
 	} else if err != nil {
-		return "", nil, err
+		return FileVersion{}, nil, err
+	}
+
+	return versionFromModel(&metadata), f, nil
+}
+
+// FindEntries lists the files contained in the given version of the files of
+// the turn; version 0 is the latest
+func (ts *Repository) FindEntries(id int64, version int) ([]FileEntry, error) {
+	_, f, err := ts.GetFile(id, version)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := openZip(f)
+	if err != nil {
+		return nil, api.ErrNotAZip
+	}
+
+	entries := make([]FileEntry, 0, len(zr.File))
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, entryFromZip(zf))
+	}
+
+	return entries, nil
+}
+
+// GetEntry opens the file named name in the given version of the files of
+// the turn; version 0 is the latest
+func (ts *Repository) GetEntry(id int64, version int, name string) (FileEntry, io.ReadCloser, error) {
+	_, f, err := ts.GetFile(id, version)
+	if err != nil {
+		return FileEntry{}, nil, err
+	}
+
+	zr, err := openZip(f)
+	if err != nil {
+		f.Close()
+		return FileEntry{}, nil, api.ErrNotAZip
+	}
+
+	for _, zf := range zr.File {
+		if zf.Name != name || zf.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			f.Close()
+			return FileEntry{}, nil, api.ErrNotAZip
+		}
+		return entryFromZip(zf), entryReader{ReadCloser: rc, file: f}, nil
 	}
 
-	return filepath.Base(metadata.Path), f, nil
+	f.Close()
+	return FileEntry{}, nil, fmt.Errorf("%w: %s is not in the zip", api.ErrNotFound, name)
+}
+
+// entryReader reads a file from a zip, closing the zip along with it
+type entryReader struct {
+	io.ReadCloser
+	file io.Closer
+}
+
+func (e entryReader) Close() error {
+	e.ReadCloser.Close()
+	return e.file.Close()
 }
@@ -2,15 +2,19 @@ package turn
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"io"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/alarmfox/game-repository/api"
 	"github.com/alarmfox/game-repository/model"
+	"github.com/alarmfox/game-repository/storage"
 	"github.com/stretchr/testify/suite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -22,6 +26,7 @@ type RepositorySuite struct {
 	suite.Suite
 	db       *gorm.DB
 	testPath string
+	storage  storage.Storage
 	service  Repository
 }
 
@@ -55,7 +60,8 @@ func (suite *RepositorySuite) SetupSuite() {
 		suite.T().Fatal(err)
 	}
 
-	suite.service = *NewRepository(db, suite.testPath)
+	suite.storage = storage.NewLocal(suite.testPath)
+	suite.service = *NewRepository(db, suite.storage, UploadPolicy{})
 }
 
 func (suite *RepositorySuite) Cleanup() {
@@ -83,6 +89,8 @@ func (suite *RepositorySuite) SeedTestData() {
 	// Create a game with rounds and turns
 	suite.T().Helper()
 
+	tests := int64(3)
+
 	// Create a test game
 	game := model.Game{
 		Name: "Test Game",
@@ -92,8 +100,8 @@ func (suite *RepositorySuite) SeedTestData() {
 				TestClassId: "test",
 				Turns: []model.Turn{
 					{
-						PlayerID: 1,          // Replace with your desired player ID
-						Scores:   "10,20,30", // Replace with your desired scores
+						PlayerID: 1,                            // Replace with your desired player ID
+						Scores:   &model.Scores{Tests: &tests}, // Replace with your desired scores
 					},
 					// Add more turns as needed
 				},
@@ -222,13 +230,13 @@ func (suite *RepositorySuite) TestSaveFile() {
 			},
 		},
 	}
-	service := NewRepository(suite.db, suite.testPath)
+	service := NewRepository(suite.db, suite.storage, UploadPolicy{})
 
 	for _, tc := range tcs {
 		suite.T().Run(tc.Name, func(t *testing.T) {
 			suite.SeedTestData()
 			defer suite.Cleanup()
-			err := service.SaveFile(tc.Input.turnId, tc.Input.content)
+			_, err := service.SaveFile(tc.Input.turnId, tc.Input.content)
 			suite.Equalf(
 				suite.ErrorIs(err, tc.Output.err), true,
 				"exptected %v; got %v", tc.Output.err, err)
@@ -285,7 +293,7 @@ func (suite *RepositorySuite) TestGetFile() {
 		suite.T().Run(tc.Name, func(t *testing.T) {
 			suite.SeedTestData()
 			defer suite.Cleanup()
-			_, f, err := suite.service.GetFile(tc.Input.turnId)
+			_, f, err := suite.service.GetFile(tc.Input.turnId, 0)
 			defer f.Close()
 			suite.Equalf(
 				suite.ErrorIs(err, tc.Output.err),
@@ -298,6 +306,118 @@ func (suite *RepositorySuite) TestGetFile() {
 
 }
 
+func (suite *RepositorySuite) TestSaveFileVersions() {
+	suite.SeedTestData()
+	defer suite.Cleanup()
+
+	content := []byte("second attempt")
+	first, err := suite.service.SaveFile(1, generateValidZipContent(suite.T(), []byte("first attempt")))
+	suite.NoError(err)
+	suite.Equal("testplayer", first.UploadedBy, "uploads are attributed to the player")
+
+	zipped, err := io.ReadAll(generateValidZipContent(suite.T(), content))
+	suite.NoError(err)
+	second, err := suite.service.SaveFile(1, bytes.NewReader(zipped))
+	suite.NoError(err)
+	suite.Equal(first.Version+1, second.Version)
+	suite.Equal("testplayer", second.UploadedBy)
+	suite.Equal(int64(len(zipped)), second.Size)
+	sum := sha256.Sum256(zipped)
+	suite.Equal(hex.EncodeToString(sum[:]), second.Checksum)
+
+	versions, err := suite.service.FindVersions(1)
+	suite.NoError(err)
+	suite.Len(versions, 3, "the seeded file is the first version")
+
+	_, f, err := suite.service.GetFile(1, first.Version)
+	suite.NoError(err)
+	f.Close()
+
+	_, _, err = suite.service.GetFile(1, second.Version+1)
+	suite.ErrorIs(err, api.ErrNotFound)
+}
+
+func (suite *RepositorySuite) TestSaveFileDeduplicates() {
+	suite.SeedTestData()
+	defer suite.Cleanup()
+
+	zipped, err := io.ReadAll(generateValidZipContent(suite.T(), []byte("same suite")))
+	suite.NoError(err)
+
+	first, err := suite.service.SaveFile(1, bytes.NewReader(zipped))
+	suite.NoError(err)
+	second, err := suite.service.SaveFile(1, bytes.NewReader(zipped))
+	suite.NoError(err)
+	suite.Equal(first.Checksum, second.Checksum)
+
+	var paths []string
+	err = suite.db.
+		Model(&model.Metadata{}).
+		Where("checksum = ?", first.Checksum).
+		Pluck("path", &paths).
+		Error
+	suite.NoError(err)
+	suite.Len(paths, 2)
+	suite.Equal(paths[0], paths[1], "identical uploads share their file")
+
+	// the file outlives a reference as long as another one is left
+	err = suite.db.
+		Model(&model.Metadata{}).
+		Where("checksum = ? and version = ?", first.Checksum, first.Version).
+		Update("turn_id", nil).
+		Error
+	suite.NoError(err)
+	suite.NoError(suite.db.Transaction(func(tx *gorm.DB) error { return RemoveBlob(tx, suite.storage, paths[0]) }))
+	suite.FileExists(path.Join(suite.testPath, paths[0]))
+
+	_, f, err := suite.service.GetFile(1, second.Version)
+	suite.NoError(err)
+	f.Close()
+}
+
+func (suite *RepositorySuite) TestFileEntries() {
+	suite.SeedTestData()
+	defer suite.Cleanup()
+
+	v, err := suite.service.SaveFile(1, generateValidZipContent(suite.T(), []byte("hello")))
+	suite.Require().NoError(err)
+
+	entries, err := suite.service.FindEntries(1, v.Version)
+	suite.NoError(err)
+	suite.Len(entries, 1)
+	suite.Equal("file.txt", entries[0].Name)
+	suite.Equal(int64(5), entries[0].Size)
+
+	entry, rc, err := suite.service.GetEntry(1, 0, "file.txt")
+	suite.Require().NoError(err)
+	content, err := io.ReadAll(rc)
+	suite.NoError(err)
+	suite.NoError(rc.Close())
+	suite.Equal("hello", string(content))
+	suite.Equal(entries[0], entry)
+
+	_, _, err = suite.service.GetEntry(1, 0, "missing.txt")
+	suite.ErrorIs(err, api.ErrNotFound)
+}
+
+func (suite *RepositorySuite) TestUpdateClosesRound() {
+	suite.SeedTestData()
+	defer suite.Cleanup()
+
+	now := time.Now()
+	_, err := suite.service.Update(1, &UpdateRequest{ClosedAt: &now}, api.Precondition(""))
+	suite.NoError(err)
+
+	var round model.Round
+	suite.NoError(suite.db.First(&round, 1).Error)
+	suite.NotNil(round.ClosedAt, "round is closed with its last turn")
+
+	var game model.Game
+	suite.NoError(suite.db.First(&game, 1).Error)
+	suite.Equal(model.GameStatusClosed, game.Status, "game is closed with its last round")
+	suite.NotNil(game.ClosedAt)
+}
+
 func TestServiceSuite(t *testing.T) {
 	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
 		t.Skip()
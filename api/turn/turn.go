@@ -1,22 +1,28 @@
 package turn
 
 import (
+	"archive/zip"
+	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alarmfox/game-repository/api"
 	"github.com/alarmfox/game-repository/model"
 )
 
 type Turn struct {
-	ID        int64      `json:"id"`
-	IsWinner  bool       `json:"isWinner"`
-	CreatedAt time.Time  `json:"createdAt"`
-	UpdatedAt time.Time  `json:"updatedAt"`
-	PlayerID  int64      `json:"playerId"`
-	RoundID   int64      `json:"roundId"`
-	Scores    string     `json:"scores"`
-	StartedAt *time.Time `json:"startedAt"`
-	ClosedAt  *time.Time `json:"closedAt"`
+	ID        int64         `json:"id"`
+	IsWinner  bool          `json:"isWinner"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	PlayerID  int64         `json:"playerId"`
+	RoundID   int64         `json:"roundId"`
+	Scores    *model.Scores `json:"scores"`
+	StartedAt *time.Time    `json:"startedAt"`
+	ClosedAt  *time.Time    `json:"closedAt"`
+	Forfeited bool          `json:"forfeited"`
 }
 type CreateRequest struct {
 	RoundId   int64      `json:"roundId"`
@@ -25,19 +31,203 @@ type CreateRequest struct {
 	ClosedAt  *time.Time `json:"closedAt,omitempty"`
 }
 
-func (CreateRequest) Validate() error {
-	return nil
+func (r CreateRequest) Validate() error {
+	return api.Validate(
+		api.Positive("roundId", r.RoundId),
+		api.NotEmpty("players", r.Players),
+		api.Each("players", r.Players, api.Required),
+		api.Unique("players", r.Players),
+		api.Before("startedAt", r.StartedAt, "closedAt", r.ClosedAt),
+	)
 }
 
 type UpdateRequest struct {
-	Scores    string     `json:"scores"`
-	IsWinner  bool       `json:"isWinner"`
-	StartedAt *time.Time `json:"startedAt,omitempty"`
-	ClosedAt  *time.Time `json:"closedAt,omitempty"`
+	Scores    *model.Scores `json:"scores"`
+	IsWinner  bool          `json:"isWinner"`
+	StartedAt *time.Time    `json:"startedAt,omitempty"`
+	ClosedAt  *time.Time    `json:"closedAt,omitempty"`
+}
+
+func (r UpdateRequest) Validate() error {
+	return api.Validate(
+		api.Optional("scores", r.Scores, api.Scores),
+		api.Before("startedAt", r.StartedAt, "closedAt", r.ClosedAt),
+	)
+}
+
+// PatchRequest is a JSON merge patch (RFC 7396) of a turn. Null scores and
+// dates are cleared; the winner flag cannot be null.
+type PatchRequest struct {
+	Scores    api.Nullable[model.Scores] `json:"scores"`
+	IsWinner  api.Nullable[bool]         `json:"isWinner"`
+	StartedAt api.Nullable[time.Time]    `json:"startedAt"`
+	ClosedAt  api.Nullable[time.Time]    `json:"closedAt"`
+}
+
+func (r PatchRequest) Validate() error {
+	return api.Validate(
+		r.IsWinner.NotNull("isWinner"),
+		api.If(r.Scores.Present(), api.Scores("scores", r.Scores.Value)),
+		api.If(r.StartedAt.Present() && r.ClosedAt.Present(),
+			api.Before("startedAt", &r.StartedAt.Value, "closedAt", &r.ClosedAt.Value)),
+	)
+}
+
+func (r *PatchRequest) updates() map[string]any {
+	updates := make(map[string]any)
+	r.Scores.Apply(updates, "scores")
+	r.IsWinner.Apply(updates, "is_winner")
+	r.StartedAt.Apply(updates, "started_at")
+	r.ClosedAt.Apply(updates, "closed_at")
+	return updates
+}
+
+// Filter selects the turns to list. Zero values match every turn.
+type Filter struct {
+	RoundID   int64
+	AccountID string
+	IsWinner  *bool
+	State     StateFilter
+	Scores    ScoreFilter
+	Sort      api.SortParams
+}
+
+// ScoreFilter sets bounds on the scores of the listed turns. Nil bounds are
+// ignored.
+type ScoreFilter struct {
+	MinLineCoverage   *float64
+	MinBranchCoverage *float64
+	MinMutationScore  *float64
+	MinTests          *int64
+	Compiled          *bool
+}
+
+// scoreColumn extracts a field of the scores document as type
+func scoreColumn(field, typ string) string {
+	return fmt.Sprintf("(turns.scores->>'%s')::%s", field, typ)
 }
 
-func (UpdateRequest) Validate() error {
-	return nil
+// sortColumns maps the fields accepted by the sort parameter to columns
+var sortColumns = map[string]string{
+	"id":             "turns.id",
+	"createdAt":      "turns.created_at",
+	"updatedAt":      "turns.updated_at",
+	"lineCoverage":   scoreColumn("lineCoverage", "numeric"),
+	"branchCoverage": scoreColumn("branchCoverage", "numeric"),
+	"mutationScore":  scoreColumn("mutationScore", "numeric"),
+	"tests":          scoreColumn("tests", "bigint"),
+}
+
+type FloatType float64
+
+func (FloatType) Parse(s string) (FloatType, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	return FloatType(f), err
+}
+
+func (f FloatType) AsFloat64() float64 {
+	return float64(f)
+}
+
+// StateFilter matches either open or closed turns
+type StateFilter string
+
+const (
+	stateOpen   StateFilter = "open"
+	stateClosed StateFilter = "closed"
+)
+
+func (StateFilter) Parse(s string) (StateFilter, error) {
+	switch st := StateFilter(strings.ToLower(s)); st {
+	case stateOpen, stateClosed:
+		return st, nil
+	default:
+		return "", fmt.Errorf("%w: supported values are %s and %s", api.ErrInvalidParam, stateOpen, stateClosed)
+	}
+}
+
+type AccountIdType string
+
+func (AccountIdType) Parse(s string) (AccountIdType, error) {
+	return AccountIdType(s), nil
+}
+
+func (a AccountIdType) AsString() string {
+	return string(a)
+}
+
+type BoolType bool
+
+func (BoolType) Parse(s string) (BoolType, error) {
+	b, err := strconv.ParseBool(s)
+	return BoolType(b), err
+}
+
+func (b BoolType) AsBool() bool {
+	return bool(b)
+}
+
+// FileVersion is an uploaded version of the files of a turn
+type FileVersion struct {
+	Version    int       `json:"version"`
+	Size       int64     `json:"size"`
+	Checksum   string    `json:"checksum"`
+	UploadedAt time.Time `json:"uploadedAt"`
+	UploadedBy string    `json:"uploadedBy"`
+}
+
+// FileEntry is a file contained in an uploaded version of the files of a
+// turn
+type FileEntry struct {
+	Name           string    `json:"name"`
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressedSize"`
+	ModifiedAt     time.Time `json:"modifiedAt"`
+}
+
+func entryFromZip(f *zip.File) FileEntry {
+	return FileEntry{
+		Name:           f.Name,
+		Size:           int64(f.UncompressedSize64),
+		CompressedSize: int64(f.CompressedSize64),
+		ModifiedAt:     f.Modified,
+	}
+}
+
+// EntryPathType is the name of a file in a zip, as in src/test/FooTest.java
+type EntryPathType string
+
+func (EntryPathType) Parse(s string) (EntryPathType, error) {
+	p, err := url.PathUnescape(s)
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", fmt.Errorf("%w: path is empty", api.ErrInvalidParam)
+	}
+	return EntryPathType(p), nil
+}
+
+func (e EntryPathType) AsString() string {
+	return string(e)
+}
+
+// VersionType is the version of a turn file. Versions start from 1.
+type VersionType int
+
+func (VersionType) Parse(s string) (VersionType, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if v < 1 {
+		return 0, fmt.Errorf("%w: versions start from 1", api.ErrInvalidParam)
+	}
+	return VersionType(v), nil
+}
+
+func (v VersionType) AsInt() int {
+	return int(v)
 }
 
 type KeyType int64
@@ -61,6 +251,17 @@ func fromModel(t *model.Turn) Turn {
 		PlayerID:  t.PlayerID,
 		StartedAt: t.StartedAt,
 		ClosedAt:  t.ClosedAt,
+		Forfeited: t.Forfeited,
 		RoundID:   t.RoundID,
 	}
 }
+
+func versionFromModel(m *model.Metadata) FileVersion {
+	return FileVersion{
+		Version:    m.Version,
+		Size:       m.Size,
+		Checksum:   m.Checksum,
+		UploadedAt: m.CreatedAt,
+		UploadedBy: m.UploadedBy,
+	}
+}
@@ -2,14 +2,20 @@ package api
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// PaginationParams selects a page either by number or, when Cursor is set,
+// by keyset. Page is ignored in the latter case.
 type PaginationParams struct {
 	Page     int64
 	PageSize int64
+	Cursor   *Cursor
 }
 
 type IntervalParams struct {
@@ -17,16 +23,45 @@ type IntervalParams struct {
 	End   time.Time
 }
 
+// SortParams represents an ordering requested by the client in the form
+// "field" or "field:direction" where direction is either "asc" or "desc".
+type SortParams struct {
+	Field string
+	Desc  bool
+}
+
+func (SortParams) Parse(s string) (SortParams, error) {
+	field, direction, found := strings.Cut(s, ":")
+	if field == "" {
+		return SortParams{}, fmt.Errorf("missing sort field")
+	}
+	if !found {
+		return SortParams{Field: field}, nil
+	}
+	switch strings.ToLower(direction) {
+	case "asc":
+		return SortParams{Field: field}, nil
+	case "desc":
+		return SortParams{Field: field, Desc: true}, nil
+	default:
+		return SortParams{}, fmt.Errorf("unsupported sort direction %q", direction)
+	}
+}
+
 type PaginatedResponse struct {
 	Data     any                `json:"data"`
 	Metadata PaginationMetadata `json:"metadata"`
 }
 
+// PaginationMetadata describes a page. Count and Page are not computed for
+// pages selected by cursor.
 type PaginationMetadata struct {
-	HasNext  bool  `json:"hasNext"`
-	Count    int64 `json:"count"`
-	Page     int64 `json:"page"`
-	PageSize int64 `json:"pageSize"`
+	HasNext  bool   `json:"hasNext"`
+	Count    int64  `json:"count"`
+	Page     int64  `json:"page"`
+	PageSize int64  `json:"pageSize"`
+	Next     string `json:"next,omitempty"`
+	Prev     string `json:"prev,omitempty"`
 }
 
 func WithPagination(p PaginationParams) func(db *gorm.DB) *gorm.DB {
@@ -42,6 +77,71 @@ func WithInterval(i IntervalParams, column string) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// WithTimeRange is like WithInterval but each bound is applied only if it is
+// not the zero time, so that ranges can be open on either side.
+func WithTimeRange(i IntervalParams, column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !i.Start.IsZero() {
+			db = db.Where(fmt.Sprintf("%s >= ?", column), i.Start)
+		}
+		if !i.End.IsZero() {
+			db = db.Where(fmt.Sprintf("%s <= ?", column), i.End)
+		}
+		return db
+	}
+}
+
+// WithEqual filters rows where column is equal to v. Empty values are ignored.
+func WithEqual(column string, v string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if v == "" {
+			return db
+		}
+		return db.Where(fmt.Sprintf("%s = ?", column), v)
+	}
+}
+
+// WithSubstring filters rows where column contains s, ignoring case. Empty
+// values are ignored.
+func WithSubstring(column string, s string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if s == "" {
+			return db
+		}
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+		return db.Where(fmt.Sprintf("%s ILIKE ?", column), "%"+escaped+"%")
+	}
+}
+
+// WithSort orders rows by the column mapped to s.Field in columns. Fields
+// without a mapping are ignored: use SortColumn to reject them beforehand.
+func WithSort(s SortParams, columns map[string]string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		column, ok := columns[s.Field]
+		if !ok {
+			return db
+		}
+		return db.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: column, Raw: true},
+			Desc:   s.Desc,
+		})
+	}
+}
+
+// SortColumn checks that s.Field is one of the sortable columns
+func SortColumn(s SortParams, columns map[string]string) error {
+	if _, ok := columns[s.Field]; !ok {
+		err := fmt.Errorf("%w %q: unsupported field %q", ErrInvalidParam, "sort", s.Field)
+		return ApiError{
+			status:  http.StatusBadRequest,
+			code:    CodeInvalidParam,
+			err:     err,
+			Message: err.Error(),
+		}
+	}
+	return nil
+}
+
 func MakePaginatedResponse(v any, count int64, p PaginationParams) *PaginatedResponse {
 	return &PaginatedResponse{
 		Data: v,
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alarmfox/game-repository/model"
+)
+
+// FieldError is a rule violated by a field of a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors lists the rules violated by a request body. Validation rules
+// return nil when the field is valid.
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	messages := make([]string, len(fe))
+	for i, e := range fe {
+		messages[i] = fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidParam, strings.Join(messages, "; "))
+}
+
+func (fe FieldErrors) Unwrap() error {
+	return ErrInvalidParam
+}
+
+// Validate collects the violations reported by rules. It returns nil when
+// every rule is satisfied.
+func Validate(rules ...FieldErrors) error {
+	if errs := All(rules...); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// All collects the violations reported by rules
+func All(rules ...FieldErrors) FieldErrors {
+	var errs FieldErrors
+	for _, r := range rules {
+		errs = append(errs, r...)
+	}
+	return errs
+}
+
+// If applies rules only when cond holds
+func If(cond bool, rules ...FieldErrors) FieldErrors {
+	if !cond {
+		return nil
+	}
+	return All(rules...)
+}
+
+func violation(field, format string, a ...any) FieldErrors {
+	return FieldErrors{{Field: field, Message: fmt.Sprintf(format, a...)}}
+}
+
+// Required fails on empty or blank strings
+func Required(field, s string) FieldErrors {
+	if strings.TrimSpace(s) == "" {
+		return violation(field, "is required")
+	}
+	return nil
+}
+
+// MaxLength fails on strings longer than n characters
+func MaxLength(field, s string, n int) FieldErrors {
+	if utf8.RuneCountInString(s) > n {
+		return violation(field, "must be at most %d characters long", n)
+	}
+	return nil
+}
+
+// Positive fails on identifiers and counters lower than 1
+func Positive[T int | int64](field string, n T) FieldErrors {
+	if n < 1 {
+		return violation(field, "must be greater than 0")
+	}
+	return nil
+}
+
+// NonNegative fails on counters lower than 0
+func NonNegative[T int | int64](field string, n T) FieldErrors {
+	if n < 0 {
+		return violation(field, "must not be negative")
+	}
+	return nil
+}
+
+// Percentage fails on values outside [0, 100]
+func Percentage(field string, v float64) FieldErrors {
+	if v < 0 || v > 100 {
+		return violation(field, "must be between 0 and 100")
+	}
+	return nil
+}
+
+// NotEmpty fails on lists without elements
+func NotEmpty[T any](field string, items []T) FieldErrors {
+	if len(items) == 0 {
+		return violation(field, "must not be empty")
+	}
+	return nil
+}
+
+// Unique fails on lists with repeated elements
+func Unique[T comparable](field string, items []T) FieldErrors {
+	seen := make(map[T]struct{}, len(items))
+	for i, item := range items {
+		if _, ok := seen[item]; ok {
+			return violation(fmt.Sprintf("%s[%d]", field, i), "is duplicated")
+		}
+		seen[item] = struct{}{}
+	}
+	return nil
+}
+
+// Each applies rule to every element of items. Fields are reported as
+// field[i].
+func Each[T any](field string, items []T, rule func(field string, item T) FieldErrors) FieldErrors {
+	var errs FieldErrors
+	for i, item := range items {
+		errs = append(errs, rule(fmt.Sprintf("%s[%d]", field, i), item)...)
+	}
+	return errs
+}
+
+// Optional applies rule to v when it is set
+func Optional[T any](field string, v *T, rule func(field string, v T) FieldErrors) FieldErrors {
+	if v == nil {
+		return nil
+	}
+	return rule(field, *v)
+}
+
+// Before fails when both dates are set and end precedes start
+func Before(startField string, start *time.Time, endField string, end *time.Time) FieldErrors {
+	if start != nil && end != nil && end.Before(*start) {
+		return violation(endField, "must not be before %s", startField)
+	}
+	return nil
+}
+
+// Scores fails on coverages and mutation scores that are not percentages and
+// on negative test counts
+func Scores(field string, s model.Scores) FieldErrors {
+	return All(
+		Optional(field+".lineCoverage", s.LineCoverage, Percentage),
+		Optional(field+".branchCoverage", s.BranchCoverage, Percentage),
+		Optional(field+".mutationScore", s.MutationScore, Percentage),
+		Optional(field+".tests", s.Tests, NonNegative[int64]),
+	)
+}
+
+// difficultyPattern matches the difficulty levels of games and robots,
+// optionally versioned as in easy_v2
+var difficultyPattern = regexp.MustCompile(`^(easy|medium|hard)(_v[0-9]+)?$`)
+
+// Difficulty fails on unknown difficulty levels
+func Difficulty(field, s string) FieldErrors {
+	if !difficultyPattern.MatchString(s) {
+		return violation(field, "must be easy, medium or hard, optionally versioned as in easy_v1")
+	}
+	return nil
+}
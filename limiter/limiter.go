@@ -1,12 +1,12 @@
 package limiter
 
 import (
-	"log"
 	"net"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/alarmfox/game-repository/api"
 	"golang.org/x/time/rate"
 )
 
@@ -67,8 +67,7 @@ func (cm *ClientLimiter) Limit(next http.Handler) http.Handler {
 		// Get the IP address for the current user.
 		ip, _, err := net.SplitHostPort(r.RemoteAddr)
 		if err != nil {
-			log.Print(err.Error())
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			api.WriteProblem(w, r, err)
 			return
 		}
 
@@ -76,7 +75,7 @@ func (cm *ClientLimiter) Limit(next http.Handler) http.Handler {
 		// the current user.
 		limiter := cm.getOrAdd(ip)
 		if !limiter.Allow() {
-			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			api.WriteProblem(w, r, api.ErrTooManyRequests)
 			return
 		}
 
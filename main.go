@@ -8,19 +8,27 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/alarmfox/game-repository/api"
+	"github.com/alarmfox/game-repository/api/archive"
 	"github.com/alarmfox/game-repository/api/game"
+	"github.com/alarmfox/game-repository/api/leaderboard"
+	"github.com/alarmfox/game-repository/api/player"
 	"github.com/alarmfox/game-repository/api/robot"
 	"github.com/alarmfox/game-repository/api/round"
 	"github.com/alarmfox/game-repository/api/turn"
 	"github.com/alarmfox/game-repository/limiter"
 	"github.com/alarmfox/game-repository/model"
+	"github.com/alarmfox/game-repository/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -29,6 +37,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Configuration struct {
@@ -38,7 +47,13 @@ type Configuration struct {
 	DataDir         string        `json:"dataDir"`
 	EnableSwagger   bool          `json:"enableSwagger"`
 	CleanupInterval time.Duration `json:"cleanupInterval"`
-	RateLimiting    struct {
+	// DeletedRetention is how long soft deleted games, rounds and turns are
+	// kept before being purged
+	DeletedRetention time.Duration `json:"deletedRetention"`
+	// SchedulerInterval is how often rounds past their time limit are
+	// closed
+	SchedulerInterval time.Duration `json:"schedulerInterval"`
+	RateLimiting      struct {
 		Burst   int     `json:"burst"`
 		MaxRate float64 `json:"maxRate"`
 		Enabled bool    `json:"enabled"`
@@ -49,6 +64,12 @@ type Configuration struct {
 		AuthEndpoint string `json:"authEndpoint"`
 		Method       string `json:"method"`
 	}
+	// Storage selects where turn files are kept. Replicas sharing a database
+	// must share the storage too.
+	Storage storage.Config `json:"storage"`
+	// Upload restricts the content of uploaded turn files. Size and entry
+	// limits default to 64MB and 1000 entries.
+	Upload turn.UploadPolicy `json:"upload"`
 }
 
 //go:embed postman
@@ -92,6 +113,26 @@ func run(ctx context.Context, c Configuration) error {
 		return err
 	}
 
+	if err := migrateGameStatus(db); err != nil {
+		return err
+	}
+
+	if err := migrateScores(db); err != nil {
+		return err
+	}
+
+	if err := migrateMetadata(db); err != nil {
+		return err
+	}
+
+	if err := migrateRoundOrders(db); err != nil {
+		return err
+	}
+
+	if err := migrateTurnIndex(db); err != nil {
+		return err
+	}
+
 	err = db.AutoMigrate(
 		&model.Game{},
 		&model.Round{},
@@ -108,18 +149,31 @@ func run(ctx context.Context, c Configuration) error {
 		return err
 	}
 
-	if err := os.Mkdir(c.DataDir, os.ModePerm); err != nil && !errors.Is(err, os.ErrExist) {
-		return fmt.Errorf("cannot create data directory: %w", err)
+	if err := migrateMetadataPaths(db, c.DataDir); err != nil {
+		return err
+	}
+
+	if c.Storage.Backend == "" || c.Storage.Backend == storage.BackendLocal {
+		if err := os.Mkdir(c.DataDir, os.ModePerm); err != nil && !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("cannot create data directory: %w", err)
+		}
+	}
+
+	store, err := storage.New(c.Storage, c.DataDir)
+	if err != nil {
+		return err
 	}
 
 	r := chi.NewRouter()
+	r.NotFound(api.NotFound)
+	r.MethodNotAllowed(api.MethodNotAllowed)
 
 	// basic cors
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Accept", "Authorization"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Accept", "Authorization", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -144,9 +198,10 @@ func run(ctx context.Context, c Configuration) error {
 
 	clientLimiter := limiter.NewClientLimiter(c.RateLimiting.Burst, c.RateLimiting.MaxRate)
 	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequestID)
 		r.Use(middleware.RealIP)
 		r.Use(middleware.Logger)
-		r.Use(middleware.Recoverer)
+		r.Use(api.Recoverer)
 
 		if c.RateLimiting.Enabled {
 			r.Use(clientLimiter.Limit)
@@ -168,10 +223,19 @@ func run(ctx context.Context, c Configuration) error {
 			roundController = round.NewController(round.NewRepository(db))
 
 			// turn endpoint
-			turnController = turn.NewController(turn.NewRepository(db, c.DataDir))
+			turnController = turn.NewController(turn.NewRepository(db, store, c.Upload))
 
 			// robot endpoint
 			robotController = robot.NewController(robot.NewRobotStorage(db))
+
+			// leaderboard endpoint
+			leaderboardController = leaderboard.NewController(leaderboard.NewRepository(db))
+
+			// player endpoint
+			playerController = player.NewController(player.NewRepository(db))
+
+			// archive endpoint
+			archiveController = archive.NewController(archive.NewRepository(db, store, c.Upload))
 		)
 
 		r.Mount(c.ApiPrefix, setupRoutes(
@@ -179,6 +243,9 @@ func run(ctx context.Context, c Configuration) error {
 			roundController,
 			turnController,
 			robotController,
+			leaderboardController,
+			playerController,
+			archiveController,
 		))
 	})
 	log.Printf("listening on %s", c.ListenAddress)
@@ -192,7 +259,11 @@ func run(ctx context.Context, c Configuration) error {
 		for {
 			select {
 			case <-time.After(c.CleanupInterval):
-				_, err := cleanup(db)
+				_, err := purge(db, time.Now().Add(-c.DeletedRetention))
+				if err != nil {
+					log.Print(err)
+				}
+				_, err = cleanup(db, store)
 				if err != nil {
 					log.Print(err)
 				}
@@ -202,6 +273,19 @@ func run(ctx context.Context, c Configuration) error {
 		}
 	})
 
+	g.Go(func() error {
+		for {
+			select {
+			case <-time.After(c.SchedulerInterval):
+				if _, err := expire(db, time.Now()); err != nil {
+					log.Print(err)
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+
 	if c.RateLimiting.Enabled {
 		g.Go(func() error {
 			for {
@@ -251,7 +335,252 @@ func startHttpServer(ctx context.Context, r chi.Router, addr string) error {
 	return server.Shutdown(ctx)
 }
 
-func cleanup(db *gorm.DB) (int64, error) {
+// migrateGameStatus adds the status of games to databases created before
+// games had a lifecycle, deriving it from their dates. AutoMigrate would add
+// the column with every game created.
+func migrateGameStatus(db *gorm.DB) error {
+	var columns []string
+
+	err := db.
+		Raw("select column_name from information_schema.columns " +
+			"where table_schema = current_schema() and table_name = 'games'").
+		Scan(&columns).
+		Error
+	if err != nil {
+		return err
+	}
+
+	// new databases get the column from AutoMigrate
+	if len(columns) == 0 {
+		return nil
+	}
+	for _, c := range columns {
+		if c == "status" {
+			return nil
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Exec(fmt.Sprintf("alter table games add column status smallint not null default %d", model.GameStatusCreated)).
+			Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Exec("update games set status = ? where closed_at is not null", model.GameStatusClosed).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Exec("update games set status = ? where started_at is not null and closed_at is null", model.GameStatusStarted).
+			Error
+	})
+	if err != nil {
+		return fmt.Errorf("cannot migrate game status: %w", err)
+	}
+
+	return nil
+}
+
+// migrateScores converts the free-form scores of turns and robots to jsonb
+// before AutoMigrate does, as it cannot cast arbitrary text
+func migrateScores(db *gorm.DB) error {
+	for _, table := range []string{"turns", "robots"} {
+		var dataType string
+
+		err := db.
+			Raw("select data_type from information_schema.columns "+
+				"where table_schema = current_schema() and table_name = ? and column_name = 'scores'", table).
+			Scan(&dataType).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if dataType != "text" {
+			continue
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var rows []struct {
+				ID     int64
+				Scores string
+			}
+
+			err := tx.
+				Table(table).
+				Select("id", "scores").
+				Where("scores is not null").
+				Scan(&rows).
+				Error
+			if err != nil {
+				return err
+			}
+
+			for _, row := range rows {
+				scores, ok := scoresDocument(row.Scores)
+				if !ok {
+					log.Printf("dropping scores of %s %d: %q is neither a number nor a JSON object", table, row.ID, row.Scores)
+				}
+
+				err := tx.
+					Table(table).
+					Where("id = ?", row.ID).
+					Update("scores", scores).
+					Error
+				if err != nil {
+					return err
+				}
+			}
+
+			// every value left is either null or a JSON object
+			return tx.
+				Exec(fmt.Sprintf("alter table %s alter column scores type jsonb using scores::jsonb", table)).
+				Error
+		})
+		if err != nil {
+			return fmt.Errorf("cannot migrate scores of %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// scoresDocument maps free-form scores to a JSON object. Plain numbers were
+// line coverages. It returns nil and false when s cannot be mapped.
+func scoresDocument(s string) (*string, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, true
+	}
+
+	if strings.HasPrefix(s, "{") {
+		if !json.Valid([]byte(s)) {
+			return nil, false
+		}
+		return &s, true
+	}
+
+	coverage, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(coverage) || math.IsInf(coverage, 0) {
+		return nil, false
+	}
+
+	doc, err := json.Marshal(model.Scores{LineCoverage: &coverage})
+	if err != nil {
+		return nil, false
+	}
+	v := string(doc)
+	return &v, true
+}
+
+// migrateRoundOrders renumbers the rounds of games with repeated orders
+// before AutoMigrate creates the unique index on them. Rounds keep their
+// relative order; ties are broken by id.
+func migrateRoundOrders(db *gorm.DB) error {
+	var columns []string
+
+	err := db.
+		Raw("select column_name from information_schema.columns " +
+			"where table_schema = current_schema() and table_name = 'rounds'").
+		Scan(&columns).
+		Error
+	if err != nil {
+		return err
+	}
+
+	// nothing to migrate on new databases
+	if len(columns) == 0 {
+		return nil
+	}
+
+	// the index covers live rounds only, but soft deletion may not exist yet
+	live := "true"
+	for _, c := range columns {
+		if c == "deleted_at" {
+			live = "deleted_at is null"
+		}
+	}
+
+	err = db.
+		Exec(fmt.Sprintf(`update rounds set "order" = numbered.rn from (`+
+			`select id, row_number() over (partition by game_id order by "order", id) as rn `+
+			`from rounds where %[1]s and game_id in (`+
+			`select game_id from rounds where %[1]s group by game_id having count(*) <> count(distinct "order"))`+
+			`) as numbered where rounds.id = numbered.id`, live)).
+		Error
+	if err != nil {
+		return fmt.Errorf("cannot migrate round orders: %w", err)
+	}
+
+	return nil
+}
+
+// migrateTurnIndex drops the unique index on the player and round of turns
+// when it still covers soft deleted turns, so that AutoMigrate creates it
+// again restricted to live turns. AutoMigrate leaves existing indexes alone.
+func migrateTurnIndex(db *gorm.DB) error {
+	var def string
+
+	err := db.
+		Raw("select indexdef from pg_indexes " +
+			"where schemaname = current_schema() and indexname = 'idx_playerturn'").
+		Scan(&def).
+		Error
+	if err != nil {
+		return err
+	}
+
+	if def == "" || strings.Contains(strings.ToLower(def), " where ") {
+		return nil
+	}
+
+	if err := db.Exec("drop index idx_playerturn").Error; err != nil {
+		return fmt.Errorf("cannot migrate turn index: %w", err)
+	}
+
+	return nil
+}
+
+// migrateMetadata drops the unique constraints that kept a single file per
+// turn and a single turn per file, now that every upload is stored as a new
+// version and identical uploads share their file
+func migrateMetadata(db *gorm.DB) error {
+	for _, name := range []string{"metadata_turn_id_key", "idx_metadata_turn_id", "metadata_path_key"} {
+		err := db.
+			Exec(fmt.Sprintf("alter table if exists metadata drop constraint if exists %s", name)).
+			Error
+		if err != nil {
+			return fmt.Errorf("cannot migrate metadata: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateMetadataPaths makes the paths of turn files relative to the
+// storage. They used to include the data directory, as in data/2023/1/5.zip
+// for files uploaded before versioning and data/blobs/ab/ab12.zip for shared
+// files.
+func migrateMetadataPaths(db *gorm.DB, dataDir string) error {
+	dir := path.Clean(dataDir) + "/"
+	err := db.
+		Model(&model.Metadata{}).
+		Where("left(path, ?) = ?", len(dir), dir).
+		Update("path", gorm.Expr("substr(path, ?)", len(dir)+1)).
+		Error
+	if err != nil {
+		return fmt.Errorf("cannot migrate metadata paths: %w", err)
+	}
+
+	return nil
+}
+
+func cleanup(db *gorm.DB, s storage.Storage) (int64, error) {
 	var (
 		metadata []model.Metadata
 		err      error
@@ -269,11 +598,24 @@ func cleanup(db *gorm.DB) (int64, error) {
 			return err
 		}
 
-		var deleted []int64
+		// files shared with live turns are kept; the others are removed along
+		// with the last of their references
+		var (
+			deleted []int64
+			removed = make(map[string]bool)
+		)
 		for _, m := range metadata {
-			if err := os.Remove(m.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
-				log.Print(err)
-			} else {
+			ok, checked := removed[m.Path]
+			if !checked {
+				if err := turn.RemoveBlob(tx, s, m.Path); err != nil {
+					log.Print(err)
+				} else {
+					ok = true
+				}
+				removed[m.Path] = ok
+			}
+
+			if ok {
 				deleted = append(deleted, m.ID)
 			}
 		}
@@ -284,6 +626,133 @@ func cleanup(db *gorm.DB) (int64, error) {
 	return n, err
 }
 
+// purge removes games, rounds and turns soft deleted before t. Files of the
+// purged turns are left without a turn and removed by cleanup.
+func purge(db *gorm.DB, t time.Time) (int64, error) {
+	var n int64
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		games := tx.
+			Unscoped().
+			Model(&model.Game{}).
+			Select("id").
+			Where("deleted_at < ?", t)
+
+		err := tx.
+			Where("game_id in (?)", games).
+			Delete(&model.PlayerGame{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		for _, m := range []any{&model.Turn{}, &model.Round{}, &model.Game{}} {
+			db := tx.
+				Unscoped().
+				Where("deleted_at < ?", t).
+				Delete(m)
+			if db.Error != nil {
+				return db.Error
+			}
+			n += db.RowsAffected
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// expire closes the rounds whose time limit, their own or the default of
+// their game, elapsed before t. Open turns are closed too and those without a
+// file are forfeited. Only rounds of started games expire: pausing a game
+// stops play, and rounds past their limit expire once it resumes. Rounds
+// without a start date have no deadline. Games locked by another transaction,
+// possibly of another instance, are skipped until the next run.
+func expire(db *gorm.DB, t time.Time) (int64, error) {
+	var (
+		expired   []model.Round
+		forfeited []int64
+	)
+
+	withExpired := func(db *gorm.DB) *gorm.DB {
+		return db.
+			Joins("join games on games.id = rounds.game_id").
+			Where("rounds.closed_at is null").
+			Where("rounds.started_at + coalesce(rounds.time_limit, games.round_time_limit) * interval '1 second' <= ?", t)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var games []int64
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Model(&model.Game{}).
+			Where("status = ?", model.GameStatusStarted).
+			Where("id in (?)", tx.Model(&model.Round{}).Scopes(withExpired).Select("rounds.game_id")).
+			Pluck("id", &games).
+			Error
+		if err != nil || len(games) == 0 {
+			return err
+		}
+
+		err = tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "rounds"}}).
+			Scopes(withExpired).
+			Where("rounds.game_id in ?", games).
+			Find(&expired).
+			Error
+		if err != nil {
+			return err
+		}
+
+		forfeited = make([]int64, len(expired))
+		for i, r := range expired {
+			turns := tx.
+				Model(&model.Turn{}).
+				Where(&model.Turn{RoundID: r.ID}).
+				Where("closed_at is null").
+				Where("not exists (select 1 from metadata where metadata.turn_id = turns.id)").
+				Updates(map[string]any{"forfeited": true, "closed_at": t})
+			if turns.Error != nil {
+				return turns.Error
+			}
+			forfeited[i] = turns.RowsAffected
+
+			err := tx.
+				Model(&model.Turn{}).
+				Where(&model.Turn{RoundID: r.ID}).
+				Where("closed_at is null").
+				Update("closed_at", t).
+				Error
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(&r).Update("closed_at", t).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, id := range games {
+			if err := round.Advance(tx, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	for i, r := range expired {
+		log.Printf("round %d of game %d expired: %d turns forfeited", r.ID, r.GameID, forfeited[i])
+	}
+
+	return int64(len(expired)), nil
+}
+
 func makeDefaults(c *Configuration) {
 	if c.ApiPrefix == "" {
 		c.ApiPrefix = "/"
@@ -301,9 +770,25 @@ func makeDefaults(c *Configuration) {
 		c.CleanupInterval = time.Hour
 	}
 
+	if int64(c.DeletedRetention) == 0 {
+		c.DeletedRetention = 30 * 24 * time.Hour
+	}
+
+	if int64(c.SchedulerInterval) == 0 {
+		c.SchedulerInterval = time.Minute
+	}
+
+	if c.Upload.MaxUncompressedSize == 0 {
+		c.Upload.MaxUncompressedSize = 64 * (1 << 20)
+	}
+
+	if c.Upload.MaxEntries == 0 {
+		c.Upload.MaxEntries = 1000
+	}
+
 }
 
-func setupRoutes(gc *game.Controller, rc *round.Controller, tc *turn.Controller, roc *robot.Controller) *chi.Mux {
+func setupRoutes(gc *game.Controller, rc *round.Controller, tc *turn.Controller, roc *robot.Controller, lc *leaderboard.Controller, pc *player.Controller, ac *archive.Controller) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(api.WithMaximumBodySize(api.DefaultBodySize))
@@ -312,20 +797,66 @@ func setupRoutes(gc *game.Controller, rc *round.Controller, tc *turn.Controller,
 		//Get game
 		r.Get("/{id}", api.HandlerFunc(gc.FindByID))
 
+		// Get game with rounds and turns
+		r.Get("/{id}/tree", api.HandlerFunc(gc.FindTreeByID))
+
 		// List games
 		r.Get("/", api.HandlerFunc(gc.List))
 
 		// Create game
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(api.AllowContentType("application/json")).
 			Post("/", api.HandlerFunc(gc.Create))
 
 		// Update game
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(api.AllowContentType("application/json")).
 			Put("/{id}", api.HandlerFunc(gc.Update))
 
+		// Patch game
+		r.With(api.AllowContentType("application/merge-patch+json", "application/json")).
+			Patch("/{id}", api.HandlerFunc(gc.Patch))
+
 		// Delete game
 		r.Delete("/{id}", api.HandlerFunc(gc.Delete))
 
+		// Start or resume game
+		r.Post("/{id}/start", api.HandlerFunc(gc.Start))
+
+		// Pause game
+		r.Post("/{id}/pause", api.HandlerFunc(gc.Pause))
+
+		// Close game
+		r.Post("/{id}/close", api.HandlerFunc(gc.Close))
+
+		// Restore deleted game
+		r.Post("/{id}/restore", api.HandlerFunc(gc.Restore))
+
+		// Declare game winners
+		r.With(api.AllowContentType("application/json")).
+			Put("/{id}/winners", api.HandlerFunc(gc.SetWinners))
+
+		// Add players to game
+		r.With(api.AllowContentType("application/json")).
+			Post("/{id}/players", api.HandlerFunc(gc.AddPlayers))
+
+		// Remove player from game
+		r.Delete("/{id}/players/{accountId}", api.HandlerFunc(gc.RemovePlayer))
+
+		// Create game rounds in bulk
+		r.With(api.AllowContentType("application/json")).
+			Post("/{id}/rounds:bulk", api.HandlerFunc(rc.CreateBulk))
+
+		// Reorder game rounds
+		r.With(api.AllowContentType("application/json")).
+			Post("/{id}/rounds/reorder", api.HandlerFunc(rc.Reorder))
+
+		// Export game archive
+		r.Get("/{id}/export", api.HandlerFunc(ac.Export))
+
+		// Import game archive
+		r.With(api.AllowContentType("application/zip"),
+			api.WithMaximumBodySize(api.MaxArchiveSize)).
+			Post("/import", api.HandlerFunc(ac.Import))
+
 	})
 
 	r.Route("/rounds", func(r chi.Router) {
@@ -336,13 +867,17 @@ func setupRoutes(gc *game.Controller, rc *round.Controller, tc *turn.Controller,
 		r.Get("/", api.HandlerFunc(rc.List))
 
 		// Create round
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(api.AllowContentType("application/json")).
 			Post("/", api.HandlerFunc(rc.Create))
 
 		// Update round
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(api.AllowContentType("application/json")).
 			Put("/{id}", api.HandlerFunc(rc.Update))
 
+		// Patch round
+		r.With(api.AllowContentType("application/merge-patch+json", "application/json")).
+			Patch("/{id}", api.HandlerFunc(rc.Patch))
+
 		// Delete round
 		r.Delete("/{id}", api.HandlerFunc(rc.Delete))
 
@@ -356,21 +891,34 @@ func setupRoutes(gc *game.Controller, rc *round.Controller, tc *turn.Controller,
 		r.Get("/", api.HandlerFunc(tc.List))
 
 		// Create turn
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(api.AllowContentType("application/json")).
 			Post("/", api.HandlerFunc(tc.Create))
 
 		// Update turn
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(api.AllowContentType("application/json")).
 			Put("/{id}", api.HandlerFunc(tc.Update))
 
+		// Patch turn
+		r.With(api.AllowContentType("application/merge-patch+json", "application/json")).
+			Patch("/{id}", api.HandlerFunc(tc.Patch))
+
 		// Delete turn
 		r.Delete("/{id}", api.HandlerFunc(tc.Delete))
 
 		// Get turn file
 		r.Get("/{id}/files", api.HandlerFunc(tc.Download))
 
+		// List uploaded versions of turn file
+		r.Get("/{id}/files/versions", api.HandlerFunc(tc.ListVersions))
+
+		// List files in turn file
+		r.Get("/{id}/files/entries", api.HandlerFunc(tc.ListEntries))
+
+		// Get a single file in turn file
+		r.Get("/{id}/files/entries/*", api.HandlerFunc(tc.DownloadEntry))
+
 		// Upload turn file
-		r.With(middleware.AllowContentType("application/zip"),
+		r.With(api.AllowContentType("application/zip"),
 			api.WithMaximumBodySize(api.MaxUploadSize)).
 			Put("/{id}/files", api.HandlerFunc(tc.Upload))
 	})
@@ -380,13 +928,33 @@ func setupRoutes(gc *game.Controller, rc *round.Controller, tc *turn.Controller,
 		r.Get("/", api.HandlerFunc(roc.FindByFilter))
 
 		// Create robots in bulk
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(api.AllowContentType("application/json")).
 			Post("/", api.HandlerFunc(roc.CreateBulk))
 
+		// Patch robot
+		r.With(api.AllowContentType("application/merge-patch+json", "application/json")).
+			Patch("/{id}", api.HandlerFunc(roc.Patch))
+
 		// Delete robots by class id
 		r.Delete("/", api.HandlerFunc(roc.Delete))
 
 	})
 
+	r.Route("/leaderboard", func(r chi.Router) {
+		// Get leaderboard
+		r.Get("/", api.HandlerFunc(lc.List))
+	})
+
+	r.Route("/players", func(r chi.Router) {
+		// Get player with statistics
+		r.Get("/{accountId}", api.HandlerFunc(pc.FindByAccountID))
+
+		// List players
+		r.Get("/", api.HandlerFunc(pc.List))
+
+		// Delete player
+		r.Delete("/{accountId}", api.HandlerFunc(pc.Delete))
+	})
+
 	return r
 }
@@ -4,9 +4,12 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alarmfox/game-repository/model"
+	"github.com/alarmfox/game-repository/storage"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -37,7 +40,7 @@ func TestCleanup(t *testing.T) {
 	}
 	seed(t, db)
 
-	n, err := cleanup(db)
+	n, err := cleanup(db, storage.NewLocal(os.TempDir()))
 
 	if err != nil {
 		t.Fatal(err)
@@ -49,6 +52,526 @@ func TestCleanup(t *testing.T) {
 
 }
 
+func TestPurge(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+
+	postgresAddr := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(postgresAddr), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+		&model.Robot{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedDeleted(t, db)
+
+	n, err := purge(db, time.Now().Add(-24*time.Hour))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the expired game with its round and turn; the recent one is kept
+	if n != 3 {
+		t.Fatalf("expected n=3; got n=%d", n)
+	}
+
+	var left int64
+	if err := db.Unscoped().Model(&model.Game{}).Count(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if left != 1 {
+		t.Fatalf("expected 1 game left; got %d", left)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+
+	postgresAddr := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(postgresAddr), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+		&model.Robot{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := seedExpired(t, db)
+
+	// pausing a game stops its rounds from expiring
+	var (
+		hour      = int64(time.Hour / time.Second)
+		startedAt = time.Now().Add(-2 * time.Hour)
+		paused    = model.Game{
+			Name:           "paused",
+			Status:         model.GameStatusPaused,
+			RoundTimeLimit: &hour,
+			Rounds:         []model.Round{{Order: 1, TestClassId: "test", StartedAt: &startedAt}},
+		}
+	)
+	if err := db.Create(&paused).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := expire(db, time.Now())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the first round is past the time limit of its game
+	if n != 1 {
+		t.Fatalf("expected n=1; got n=%d", n)
+	}
+
+	var turns []model.Turn
+	if err := db.Order("id asc").Find(&turns).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if turns[0].ClosedAt == nil || turns[0].Forfeited {
+		t.Fatalf("expected turn with file to be closed and not forfeited")
+	}
+
+	if turns[1].ClosedAt == nil || !turns[1].Forfeited {
+		t.Fatalf("expected turn without file to be forfeited")
+	}
+
+	if err := db.First(&game, game.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if game.CurrentRound != 2 {
+		t.Fatalf("expected current round 2; got %d", game.CurrentRound)
+	}
+	var round model.Round
+	if err := db.Where("game_id = ?", paused.ID).First(&round).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if round.ClosedAt != nil {
+		t.Fatalf("expected round of paused game to stay open")
+	}
+}
+
+func TestMigrateMetadataPaths(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+
+	postgresAddr := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(postgresAddr), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the default data directory is relative to the working directory
+	dataDir := "data"
+	paths := map[string]string{
+		"data/2023/1/5.zip":      "2023/1/5.zip",
+		"data/blobs/ab/ab12.zip": "blobs/ab/ab12.zip",
+		"blobs/cd/cd34.zip":      "blobs/cd/cd34.zip",
+		"/var/data/2023/1/7.zip": "/var/data/2023/1/7.zip",
+		"database/2023/1/8.zip":  "database/2023/1/8.zip",
+	}
+
+	toInsert := make([]model.Metadata, 0, len(paths))
+	for p := range paths {
+		toInsert = append(toInsert, model.Metadata{Path: p})
+	}
+	if err := db.Create(&toInsert).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Delete(&toInsert)
+	})
+
+	if err := migrateMetadataPaths(db, dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range toInsert {
+		var got model.Metadata
+		if err := db.First(&got, m.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if expected := paths[m.Path]; got.Path != expected {
+			t.Errorf("expected %s to become %s; got %s", m.Path, expected, got.Path)
+		}
+	}
+}
+
+func TestMigrateGameStatus(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+
+	postgresAddr := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(postgresAddr), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+		&model.Robot{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// databases created before games had a lifecycle have no status
+	if err := db.Exec("alter table games drop column status").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		now      = time.Now()
+		expected = map[string]model.GameStatus{
+			"legacy created": model.GameStatusCreated,
+			"legacy started": model.GameStatusStarted,
+			"legacy closed":  model.GameStatusClosed,
+		}
+		dates = map[string][2]*time.Time{
+			"legacy created": {nil, nil},
+			"legacy started": {&now, nil},
+			"legacy closed":  {&now, &now},
+		}
+	)
+	for name, d := range dates {
+		err := db.
+			Exec("insert into games (name, started_at, closed_at, created_at, updated_at) values (?, ?, ?, ?, ?)",
+				name, d[0], d[1], now, now).
+			Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Where("name like 'legacy %'").Delete(&model.Game{})
+	})
+
+	if err := migrateGameStatus(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var games []model.Game
+	if err := db.Where("name like 'legacy %'").Find(&games).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != len(expected) {
+		t.Fatalf("expected %d games; got %d", len(expected), len(games))
+	}
+	for _, g := range games {
+		if g.Status != expected[g.Name] {
+			t.Errorf("expected %s to be %d; got %d", g.Name, expected[g.Name], g.Status)
+		}
+	}
+
+	// statuses set since are left alone
+	if err := migrateGameStatus(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Game{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateRoundOrders(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+
+	postgresAddr := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(postgresAddr), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+		&model.Robot{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// databases created before orders were unique hold repeated orders
+	if err := db.Exec("drop index if exists idx_roundorder").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	game := model.Game{
+		Name: "duplicated orders",
+		Rounds: []model.Round{
+			{Order: 2, TestClassId: "c"},
+			{Order: 1, TestClassId: "a"},
+			{Order: 1, TestClassId: "b"},
+		},
+	}
+	if err := db.Create(&game).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Where("game_id = ?", game.ID).Delete(&model.Round{})
+		db.Unscoped().Delete(&game)
+	})
+
+	if err := migrateRoundOrders(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var rounds []model.Round
+	if err := db.Where("game_id = ?", game.ID).Order(`"order" asc`).Find(&rounds).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{"a", "b", "c"} {
+		if rounds[i].Order != i+1 || rounds[i].TestClassId != expected {
+			t.Fatalf("expected round %s to be number %d; got %s", expected, i+1, rounds[i].TestClassId)
+		}
+	}
+
+	if err := db.AutoMigrate(&model.Round{}); err != nil {
+		t.Fatalf("expected the unique index to be created: %v", err)
+	}
+}
+
+func TestMigrateTurnIndex(t *testing.T) {
+	if _, ok := os.LookupEnv("SKIP_INTEGRATION"); ok {
+		t.Skip()
+	}
+
+	postgresAddr := os.Getenv("DB_URI")
+	db, err := gorm.Open(postgres.Open(postgresAddr), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = db.AutoMigrate(
+		&model.Game{},
+		&model.Round{},
+		&model.Player{},
+		&model.Turn{},
+		&model.Metadata{},
+		&model.PlayerGame{},
+		&model.Robot{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// databases created before soft deletion have an index on every turn
+	for _, stmt := range []string{
+		"drop index if exists idx_playerturn",
+		"create unique index idx_playerturn on turns (player_id, round_id)",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateTurnIndex(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.Turn{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var def string
+	err = db.
+		Raw("select indexdef from pg_indexes where indexname = 'idx_playerturn'").
+		Scan(&def).
+		Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(def, "WHERE") {
+		t.Fatalf("expected the index to skip soft deleted turns; got %s", def)
+	}
+}
+
+func TestScoresDocument(t *testing.T) {
+	tcs := []struct {
+		Name     string
+		Scores   string
+		Expected string
+		Ok       bool
+	}{
+		{Name: "Object", Scores: ` {"tests": 3}`, Expected: `{"tests": 3}`, Ok: true},
+		{Name: "Number", Scores: "87.5", Expected: `{"lineCoverage":87.5}`, Ok: true},
+		{Name: "Blank", Scores: "  ", Ok: true},
+		{Name: "MalformedObject", Scores: `{"tests": `},
+		{Name: "Text", Scores: "good"},
+		{Name: "NaN", Scores: "NaN"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, ok := scoresDocument(tc.Scores)
+			if ok != tc.Ok {
+				t.Fatalf("expected ok=%v; got ok=%v", tc.Ok, ok)
+			}
+			if (got == nil) != (tc.Expected == "") || (got != nil && *got != tc.Expected) {
+				t.Fatalf("expected %q; got %v", tc.Expected, got)
+			}
+		})
+	}
+}
+
+func seedExpired(t *testing.T, db *gorm.DB) model.Game {
+	t.Helper()
+
+	players := []model.Player{{AccountID: "expired1"}, {AccountID: "expired2"}}
+	if err := db.Create(&players).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		hour      = int64(time.Hour / time.Second)
+		day       = 24 * hour
+		startedAt = time.Now().Add(-2 * time.Hour)
+		game      = model.Game{
+			Name:           "expired",
+			Status:         model.GameStatusStarted,
+			RoundTimeLimit: &hour,
+			Rounds: []model.Round{
+				{
+					Order:       1,
+					TestClassId: "test",
+					StartedAt:   &startedAt,
+					Turns: []model.Turn{
+						{PlayerID: players[0].ID, Files: []model.Metadata{{Path: "expired.zip"}}},
+						{PlayerID: players[1].ID},
+					},
+				},
+				{
+					Order:       2,
+					TestClassId: "test",
+					StartedAt:   &startedAt,
+					TimeLimit:   &day,
+				},
+			},
+		}
+	)
+
+	if err := db.Create(&game).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		for _, m := range []any{&model.Metadata{}, &model.Turn{}, &model.Round{}, &model.Game{}, &model.Player{}} {
+			err := db.
+				Session(&gorm.Session{AllowGlobalUpdate: true}).
+				Unscoped().
+				Delete(m).
+				Error
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	return game
+}
+
+func seedDeleted(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	player := model.Player{AccountID: "purged"}
+	if err := db.Create(&player).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		expired = gorm.DeletedAt{Valid: true, Time: time.Now().Add(-48 * time.Hour)}
+		recent  = gorm.DeletedAt{Valid: true, Time: time.Now().Add(-time.Hour)}
+		games   = []model.Game{
+			{
+				Name:      "expired",
+				DeletedAt: expired,
+				Rounds: []model.Round{
+					{
+						TestClassId: "test",
+						DeletedAt:   expired,
+						Turns: []model.Turn{
+							{PlayerID: player.ID, DeletedAt: expired},
+						},
+					},
+				},
+			},
+			{
+				Name:      "recent",
+				DeletedAt: recent,
+			},
+		}
+	)
+
+	if err := db.Create(&games).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		for _, m := range []any{&model.Turn{}, &model.Round{}, &model.Game{}, &model.Player{}} {
+			err := db.
+				Session(&gorm.Session{AllowGlobalUpdate: true}).
+				Unscoped().
+				Delete(m).
+				Error
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
 func seed(t *testing.T, db *gorm.DB) {
 	t.Helper()
 
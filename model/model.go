@@ -3,20 +3,37 @@ package model
 import (
 	"database/sql"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+type GameStatus int8
+
+const (
+	GameStatusCreated GameStatus = iota
+	GameStatusStarted
+	GameStatusPaused
+	GameStatusClosed
 )
 
 type Game struct {
-	CurrentRound int   `gorm:"default:1"`
-	ID           int64 `gorm:"primaryKey;autoIncrement"`
-	Name         string
-	Description  sql.NullString `gorm:"default:null"`
-	Difficulty   string
-	CreatedAt    time.Time  `gorm:"autoCreateTime"`
-	UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
-	StartedAt    *time.Time `gorm:"default:null"`
-	ClosedAt     *time.Time `gorm:"default:null"`
-	Rounds       []Round    `gorm:"foreignKey:GameID;constraint:OnDelete:CASCADE;"`
-	Players      []Player   `gorm:"many2many:player_games;foreignKey:ID;joinForeignKey:GameID;References:AccountID;joinReferences:PlayerID"`
+	CurrentRound   int   `gorm:"default:1"`
+	ID             int64 `gorm:"primaryKey;autoIncrement;index:idx_gamecursor,priority:2"`
+	Name           string
+	Description    sql.NullString `gorm:"default:null"`
+	Difficulty     string
+	Status         GameStatus     `gorm:"not null;default:0"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime;index:idx_gamecursor,priority:1"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime"`
+	StartedAt      *time.Time     `gorm:"default:null"`
+	ClosedAt       *time.Time     `gorm:"default:null"`
+	RoundTimeLimit *int64         `gorm:"default:null"`
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+	Rounds         []Round        `gorm:"foreignKey:GameID;constraint:OnDelete:CASCADE;"`
+	Players        []Player       `gorm:"many2many:player_games;foreignKey:ID;joinForeignKey:GameID;References:AccountID;joinReferences:PlayerID"`
+	// CurrentRoundID is derived from CurrentRound and filled only by
+	// queries selecting it
+	CurrentRoundID *int64 `gorm:"->;-:migration"`
 }
 
 func (Game) TableName() string {
@@ -36,9 +53,9 @@ func (PlayerGame) TableName() string {
 }
 
 type Player struct {
-	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	ID        int64     `gorm:"primaryKey;autoIncrement;index:idx_playercursor,priority:2"`
 	AccountID string    `gorm:"unique"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_playercursor,priority:1"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 	Turns     []Turn    `gorm:"foreignKey:PlayerID;constraint:OnDelete:SET NULL;"`
 	Games     []Game    `gorm:"many2many:player_games;foreignKey:AccountID;joinForeignKey:PlayerID;"`
@@ -49,15 +66,17 @@ func (Player) TableName() string {
 }
 
 type Round struct {
-	ID          int64      `gorm:"primaryKey;autoIncrement"`
-	Order       int        `gorm:"not null;default:1"`
-	StartedAt   *time.Time `gorm:"default:null"`
-	ClosedAt    *time.Time `gorm:"default:null"`
-	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
-	CreatedAt   time.Time  `gorm:"autoCreateTime"`
-	Turns       []Turn     `gorm:"foreignKey:RoundID;constraint:OnDelete:CASCADE;"`
-	TestClassId string     `gorm:"not null"`
-	GameID      int64      `gorm:"not null"`
+	ID          int64          `gorm:"primaryKey;autoIncrement"`
+	Order       int            `gorm:"not null;default:1;index:idx_roundorder,unique,priority:2"`
+	StartedAt   *time.Time     `gorm:"default:null"`
+	ClosedAt    *time.Time     `gorm:"default:null"`
+	TimeLimit   *int64         `gorm:"default:null"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	Turns       []Turn         `gorm:"foreignKey:RoundID;constraint:OnDelete:CASCADE;"`
+	TestClassId string         `gorm:"not null"`
+	GameID      int64          `gorm:"not null;index:idx_roundorder,unique,priority:1,where:deleted_at IS NULL"`
 }
 
 func (Round) TableName() string {
@@ -65,28 +84,37 @@ func (Round) TableName() string {
 }
 
 type Turn struct {
-	ID        int64      `gorm:"primaryKey;autoIncrement"`
-	CreatedAt time.Time  `gorm:"autoCreateTime"`
-	UpdatedAt time.Time  `gorm:"autoUpdateTime"`
-	StartedAt *time.Time `gorm:"default:null"`
-	ClosedAt  *time.Time `gorm:"default:null"`
-	Metadata  Metadata   `gorm:"foreignKey:TurnID;constraint:OnDelete:SET NULL;"`
-	Scores    string     `gorm:"default:null"`
-	IsWinner  bool       `gorm:"default:false"`
-	PlayerID  int64      `gorm:"index:idx_playerturn,unique;not null"`
-	RoundID   int64      `gorm:"index:idx_playerturn,unique;not null"`
+	ID        int64          `gorm:"primaryKey;autoIncrement"`
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
+	StartedAt *time.Time     `gorm:"default:null"`
+	ClosedAt  *time.Time     `gorm:"default:null"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Files     []Metadata     `gorm:"foreignKey:TurnID;constraint:OnDelete:SET NULL;"`
+	Scores    *Scores        `gorm:"type:jsonb;default:null"`
+	IsWinner  bool           `gorm:"default:false"`
+	Forfeited bool           `gorm:"not null;default:false"`
+	PlayerID  int64          `gorm:"index:idx_playerturn,unique,where:deleted_at IS NULL;not null"`
+	RoundID   int64          `gorm:"index:idx_playerturn,unique;not null"`
 }
 
 func (Turn) TableName() string {
 	return "turns"
 }
 
+// Metadata describes an uploaded turn file. Every upload is a new version of
+// the files of its turn. Uploads with the same content share the file at
+// Path, which is removed with its last reference.
 type Metadata struct {
-	ID        int64         `gorm:"primaryKey;autoIncrement"`
-	CreatedAt time.Time     `gorm:"autoCreateTime"`
-	UpdatedAt time.Time     `gorm:"autoUpdateTime"`
-	TurnID    sql.NullInt64 `gorm:"unique"`
-	Path      string        `gorm:"unique;not null"`
+	ID         int64         `gorm:"primaryKey;autoIncrement"`
+	CreatedAt  time.Time     `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time     `gorm:"autoUpdateTime"`
+	TurnID     sql.NullInt64 `gorm:"index:idx_turnversion,unique,priority:1"`
+	Version    int           `gorm:"not null;default:1;index:idx_turnversion,unique,priority:2"`
+	Path       string        `gorm:"not null;index"`
+	Size       int64         `gorm:"not null;default:0"`
+	Checksum   string        `gorm:"default:null"`
+	UploadedBy string        `gorm:"default:null"`
 }
 
 func (Metadata) TableName() string {
@@ -98,7 +126,7 @@ type Robot struct {
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
 	TestClassId string    `gorm:"not null;index:idx_robotquery"`
-	Scores      string    `gorm:"default:null"`
+	Scores      *Scores   `gorm:"type:jsonb;default:null"`
 	Difficulty  string    `gorm:"not null;index:idx_robotquery"`
 	Type        int8      `gorm:"not null;index:idx_robotquery"`
 }
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Scores is the outcome of the tests written in a turn or generated by a
+// robot. It is stored as a jsonb document; absent fields were not measured.
+type Scores struct {
+	LineCoverage   *float64 `json:"lineCoverage,omitempty"`
+	BranchCoverage *float64 `json:"branchCoverage,omitempty"`
+	MutationScore  *float64 `json:"mutationScore,omitempty"`
+	Tests          *int64   `json:"tests,omitempty"`
+	Compiled       *bool    `json:"compiled,omitempty"`
+}
+
+// Value encodes the scores as text, which the driver passes to jsonb columns
+// unchanged
+func (s Scores) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *Scores) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case nil:
+		*s = Scores{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into scores", src)
+	}
+}
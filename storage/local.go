@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local stores objects as files under a root directory
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{
+		root: root,
+	}
+}
+
+// path maps key to a file under the root. Absolute keys, left by files
+// stored outside the data directory, are used as they are.
+func (l *Local) path(key string) string {
+	if filepath.IsAbs(key) {
+		return key
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key))
+}
+
+// Put writes the object to a temporary file first, so that readers never
+// see it partially written
+func (l *Local) Put(key string, r io.Reader, size int64) error {
+	fname := l.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(fname), os.ModePerm); err != nil && !errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fname), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.CopyN(tmp, r, size); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fname)
+}
+
+func (l *Local) Open(key string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotExist
+	}
+	return f, err
+}
+
+func (l *Local) Exists(key string) (bool, error) {
+	_, err := os.Stat(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *Local) Remove(key string) error {
+	if err := os.Remove(l.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Memory stores objects in a map. It is meant for tests: objects are lost
+// on restart.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		objects: make(map[string][]byte),
+	}
+}
+
+func (m *Memory) Put(key string, r io.Reader, size int64) error {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = buf
+	return nil
+}
+
+func (m *Memory) Open(key string) (io.ReadSeekCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	buf, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return nopCloser{bytes.NewReader(buf)}, nil
+}
+
+func (m *Memory) Exists(key string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+func (m *Memory) Remove(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
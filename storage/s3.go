@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket of an S3 compatible service
+type S3Config struct {
+	Endpoint        string `json:"endpoint"`
+	Bucket          string `json:"bucket"`
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	// PathStyle addresses the bucket as the first segment of the path rather
+	// than as a subdomain of the endpoint, as most self-hosted services
+	// require
+	PathStyle bool `json:"pathStyle"`
+}
+
+// S3 stores objects in a bucket of an S3 compatible service. Requests are
+// signed with AWS Signature Version 4.
+type S3 struct {
+	config S3Config
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3(c S3Config) (*S3, error) {
+	if c.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+
+	base, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %w", err)
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", c.Endpoint)
+	}
+
+	return &S3{
+		config: c,
+		base:   base,
+		client: &http.Client{Timeout: time.Minute},
+		now:    time.Now,
+	}, nil
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64) error {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+
+	res, err := s.do(http.MethodPut, key, buf)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkStatus(res)
+}
+
+// Open downloads the whole object, so that it can be seeked
+func (s *S3) Open(key string) (io.ReadSeekCloser, error) {
+	res, err := s.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := checkStatus(res); err != nil {
+		return nil, err
+	}
+
+	buf, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(buf)}, nil
+}
+
+func (s *S3) Exists(key string) (bool, error) {
+	res, err := s.do(http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if err := checkStatus(res); errors.Is(err, ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *S3) Remove(key string) error {
+	res, err := s.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := checkStatus(res); err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// url addresses key in the bucket
+func (s *S3) url(key string) *url.URL {
+	u := *s.base
+	key = strings.TrimPrefix(key, "/")
+	if s.config.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+func (s *S3) do(method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.url(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+
+	s.sign(req, body, s.now().UTC())
+	return s.client.Do(req)
+}
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	amzDate       = "20060102T150405Z"
+)
+
+// sign adds the Authorization header of AWS Signature Version 4 to req,
+// covering the host and every header already set
+func (s *S3) sign(req *http.Request, body []byte, t time.Time) {
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", t.Format(amzDate))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	day := t.Format("20060102")
+	scope := strings.Join([]string{day, s.config.Region, "s3", "aws4_request"}, "/")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		t.Format(amzDate),
+		scope,
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), day)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, s.config.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := q[k]
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func checkStatus(res *http.Response) error {
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrNotExist
+	case res.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("s3: unexpected status %s: %s", res.Status, bytes.TrimSpace(msg))
+	default:
+		return nil
+	}
+}
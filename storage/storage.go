@@ -0,0 +1,55 @@
+// Package storage keeps the uploaded turn files. Backends store objects by
+// key, a slash separated path relative to the root of the backend.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotExist is returned when no object is stored under a key
+var ErrNotExist = errors.New("object does not exist")
+
+// Storage is a backend for turn files. Objects are small enough to be held
+// in memory, so backends may buffer them.
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing any object
+	// stored there
+	Put(key string, r io.Reader, size int64) error
+	// Open returns the object stored under key
+	Open(key string) (io.ReadSeekCloser, error)
+	// Exists reports whether an object is stored under key
+	Exists(key string) (bool, error)
+	// Remove deletes the object stored under key. Missing objects are not
+	// an error.
+	Remove(key string) error
+}
+
+const (
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+	BackendS3     = "s3"
+)
+
+// Config selects the backend. Replicas of the service share turn files only
+// through the s3 backend.
+type Config struct {
+	Backend string   `json:"backend"`
+	S3      S3Config `json:"s3"`
+}
+
+// New returns the backend selected by c. The local backend stores objects
+// under dataDir.
+func New(c Config, dataDir string) (Storage, error) {
+	switch c.Backend {
+	case "", BackendLocal:
+		return NewLocal(dataDir), nil
+	case BackendMemory:
+		return NewMemory(), nil
+	case BackendS3:
+		return NewS3(c.S3)
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", c.Backend)
+	}
+}